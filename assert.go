@@ -0,0 +1,111 @@
+package main
+
+import "strings"
+
+func init() {
+	builtins["assert_eq"] = &Builtin{Name: "assert_eq", Fn: builtinAssertEq}
+	builtins["assert_true"] = &Builtin{Name: "assert_true", Fn: builtinAssertTrue}
+	builtins["assert_error"] = &Builtin{Name: "assert_error", Fn: builtinAssertError}
+}
+
+// newAssertionError reports a failed assertion, with the optional message
+// the script passed as the last argument
+func newAssertionError(extra []Object, format string, a ...interface{}) *Error {
+	err := newErrorKind(ASSERTION_ERROR, format, a...)
+	if len(extra) == 1 {
+		if msg, ok := extra[0].(*String); ok {
+			err.Message += ": " + msg.Value
+		} else {
+			err.Message += ": " + extra[0].Inspect()
+		}
+	}
+	return err
+}
+
+// builtinAssertEq fails unless its first two arguments are equal
+func builtinAssertEq(_ *Environment, args ...Object) Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to assert_eq: got=%d, want 2 to 3", len(args))
+	}
+	if !objectsEqual(args[0], args[1]) {
+		return newAssertionError(args[2:], "assert_eq failed: got %s, want %s", args[0].Inspect(), args[1].Inspect())
+	}
+	return NULL
+}
+
+// builtinAssertTrue fails unless its argument is truthy
+func builtinAssertTrue(_ *Environment, args ...Object) Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to assert_true: got=%d, want 1 to 2", len(args))
+	}
+	if !isTruthy(args[0]) {
+		return newAssertionError(args[1:], "assert_true failed: got %s", args[0].Inspect())
+	}
+	return NULL
+}
+
+// builtinAssertError calls a function with the remaining arguments and
+// fails unless the call raises an error or returns an Err. The error is
+// returned as an Err value so the test can inspect it.
+func builtinAssertError(env *Environment, args ...Object) Object {
+	if len(args) < 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to assert_error: got=%d, want at least 1", len(args))
+	}
+	switch args[0].(type) {
+	case *Function, *Builtin:
+	default:
+		return newErrorKind(TYPE_ERROR, "argument to assert_error must be a function, got %s", args[0].Type())
+	}
+
+	result := applyFunction(args[0], args[1:], env)
+	switch result := result.(type) {
+	case *Error:
+		return &Err{Error: result}
+	case *Err:
+		return result
+	}
+
+	inspected := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		inspected[i] = arg.Inspect()
+	}
+	return newErrorKind(ASSERTION_ERROR, "assert_error failed: %s(%s) returned %s",
+		traceName(args[0]), strings.Join(inspected, ", "), result.Inspect())
+}
+
+// objectsEqual compares values structurally: arrays, Ok and Err values by
+// their contents and other values as the == operator does
+func objectsEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *BigInt:
+		b, ok := b.(*BigInt)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *Decimal:
+		b, ok := b.(*Decimal)
+		return ok && decimalCmp(a, b) == 0
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !objectsEqual(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Ok:
+		b, ok := b.(*Ok)
+		return ok && objectsEqual(a.Value, b.Value)
+	case *Err:
+		b, ok := b.(*Err)
+		return ok && a.Error.Kind == b.Error.Kind && a.Error.Message == b.Error.Message
+	}
+	return a == b
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
+	"sync/atomic"
 )
 
 // Node represents any node in the AST
@@ -55,11 +57,16 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
-// FunctionStatement represents function declarations
+// FunctionStatement represents function declarations. Defaults holds the
+// default value of each parameter, or nil where there is none, and Rest is
+// the optional variadic parameter collecting the remaining arguments.
 type FunctionStatement struct {
 	Token      Token
+	Doc        string // comment lines directly above the declaration
 	Name       *Identifier
 	Parameters []*Identifier
+	Defaults   []Expression
+	Rest       *Identifier
 	Body       *BlockStatement
 }
 
@@ -70,17 +77,29 @@ func (fs *FunctionStatement) String() string {
 	out.WriteString(fs.Name.String())
 	out.WriteString("(")
 
-	params := []string{}
-	for _, p := range fs.Parameters {
-		params = append(params, p.String())
-	}
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(formatParameters(fs.Parameters, fs.Defaults, fs.Rest))
 
 	out.WriteString(") ")
 	out.WriteString(fs.Body.String())
 	return out.String()
 }
 
+// formatParameters renders a parameter list with defaults and rest parameter
+func formatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	out := []string{}
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			out = append(out, p.String()+" = "+defaults[i].String())
+		} else {
+			out = append(out, p.String())
+		}
+	}
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+	return strings.Join(out, ", ")
+}
+
 // ReturnStatement represents return statements
 type ReturnStatement struct {
 	Token       Token
@@ -123,6 +142,54 @@ func (ifs *IfStatement) String() string {
 	return out.String()
 }
 
+// ThrowStatement represents raising an error like "throw "bad input";"
+type ThrowStatement struct {
+	Token Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) String() string {
+	var out strings.Builder
+	out.WriteString("throw ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// TryStatement represents try { } catch (e) { } finally { } statements.
+// Either the catch or the finally clause may be omitted, but not both.
+type TryStatement struct {
+	Token      Token
+	Block      *BlockStatement
+	CatchParam *Identifier
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (ts *TryStatement) statementNode() {}
+func (ts *TryStatement) String() string {
+	var out strings.Builder
+	out.WriteString("try ")
+	out.WriteString(ts.Block.String())
+
+	if ts.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(ts.CatchParam.String())
+		out.WriteString(") ")
+		out.WriteString(ts.Catch.String())
+	}
+
+	if ts.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(ts.Finally.String())
+	}
+
+	return out.String()
+}
+
 // ExpressionStatement represents expressions that are used as statements
 type ExpressionStatement struct {
 	Token      Token
@@ -137,10 +204,12 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
-// BlockStatement represents a block of statements enclosed in braces
+// BlockStatement represents a block of statements enclosed in braces.
+// RBrace is the closing brace.
 type BlockStatement struct {
 	Token      Token
 	Statements []Statement
+	RBrace     Token
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -168,20 +237,55 @@ func (i *Identifier) String() string  { return i.Value }
 type IntegerLiteral struct {
 	Token Token
 	Value int64
+	Big   *big.Int // the value, if it does not fit in Value
+
+	object atomic.Pointer[Integer] // the value, once evaluated
 }
 
 func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) String() string  { return il.Token.Literal }
 
+// Object returns the literal's value. Objects are immutable, so every
+// evaluation of the literal shares the one created first.
+func (il *IntegerLiteral) Object() *Integer {
+	if obj := il.object.Load(); obj != nil {
+		return obj
+	}
+	obj := newInteger(il.Value)
+	il.object.Store(obj)
+	return obj
+}
+
 // StringLiteral represents string literals
 type StringLiteral struct {
 	Token Token
 	Value string
+
+	object atomic.Pointer[String] // the value, once evaluated
 }
 
 func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) String() string  { return fmt.Sprintf(`"%s"`, sl.Value) }
 
+// Object returns the literal's value, shared by all its evaluations
+func (sl *StringLiteral) Object() *String {
+	if obj := sl.object.Load(); obj != nil {
+		return obj
+	}
+	obj := &String{Value: sl.Value}
+	sl.object.Store(obj)
+	return obj
+}
+
+// DecimalLiteral represents decimal literals such as 12.50d
+type DecimalLiteral struct {
+	Token Token
+	Value *Decimal // shared by every evaluation of the literal
+}
+
+func (dl *DecimalLiteral) expressionNode() {}
+func (dl *DecimalLiteral) String() string  { return dl.Token.Literal }
+
 // BooleanLiteral represents boolean literals (true/false)
 type BooleanLiteral struct {
 	Token Token
@@ -251,3 +355,85 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+// SpawnExpression represents starting a call on a new task like spawn f(x)
+type SpawnExpression struct {
+	Token Token
+	Call  *CallExpression
+}
+
+func (se *SpawnExpression) expressionNode() {}
+func (se *SpawnExpression) String() string {
+	return "spawn " + se.Call.String()
+}
+
+// PropertyExpression represents property access like err.message
+type PropertyExpression struct {
+	Token    Token
+	Object   Expression
+	Property *Identifier
+}
+
+func (pe *PropertyExpression) expressionNode() {}
+func (pe *PropertyExpression) String() string {
+	return pe.Object.String() + "." + pe.Property.String()
+}
+
+// PropagateExpression represents the postfix error propagation operator
+// like int(input)?
+type PropagateExpression struct {
+	Token Token
+	Value Expression
+}
+
+func (pe *PropagateExpression) expressionNode() {}
+func (pe *PropagateExpression) String() string {
+	return pe.Value.String() + "?"
+}
+
+// NamedArgument represents an argument passed by name like greet(name: "Bob")
+type NamedArgument struct {
+	Token Token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode() {}
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+// IndexExpression represents indexing like nums[0]
+type IndexExpression struct {
+	Token Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) String() string {
+	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
+}
+
+// statementToken returns the token a statement is reported at
+func statementToken(stmt Statement) Token {
+	switch s := stmt.(type) {
+	case *LetStatement:
+		return s.Token
+	case *FunctionStatement:
+		return s.Token
+	case *ReturnStatement:
+		return s.Token
+	case *IfStatement:
+		return s.Token
+	case *TryStatement:
+		return s.Token
+	case *ThrowStatement:
+		return s.Token
+	case *ExpressionStatement:
+		return s.Token
+	case *BlockStatement:
+		return s.Token
+	}
+	return Token{}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ASTSchemaVersion is the version of the JSON emitted by "tinylang tokens"
+// and "tinylang ast". It changes whenever a field is renamed or removed, so
+// external tools can reject output they do not understand.
+//
+// Every AST node is an object with "type" (the node's Go type name, such as
+// "LetStatement"), "line" and "column" (the position of the node's token,
+// 1-based) and the fields listed in nodeJSON. Optional children are null
+// when absent and lists are always present, possibly empty.
+const ASTSchemaVersion = 1
+
+// astDumpFlags parses the flags shared by the tokens and ast subcommands and
+// returns the source file to read
+func astDumpFlags(name string, args []string, stderr io.Writer) (path string, asJSON bool, ok bool) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output `format`: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: tinylang %s [-format text|json] <file.tiny>\n", name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return "", false, false
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return "", false, false
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "%s: unknown format %q\n", name, *format)
+		return "", false, false
+	}
+	return flags.Arg(0), *format == "json", true
+}
+
+// runTokens implements "tinylang tokens [-format text|json] file.tiny"
+func runTokens(args []string, stdout, stderr io.Writer) int {
+	path, asJSON, ok := astDumpFlags("tokens", args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "tokens: %v\n", err)
+		return exitUsage
+	}
+
+	tokens := New(string(content)).TokenizeAll()
+	if asJSON {
+		list := make([]interface{}, len(tokens))
+		for i, tok := range tokens {
+			list[i] = map[string]interface{}{
+				"type":    tok.Type.String(),
+				"literal": tok.Literal,
+				"line":    tok.Line,
+				"column":  tok.Column,
+			}
+		}
+		return writeASTJSON(stdout, stderr, map[string]interface{}{"version": ASTSchemaVersion, "tokens": list})
+	}
+
+	for _, tok := range tokens {
+		fmt.Fprintf(stdout, "%d:%d\t%-10s %q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+	return exitOK
+}
+
+// runAST implements "tinylang ast [-format text|json] file.tiny". Parse
+// errors are reported on stderr and no tree is printed.
+func runAST(args []string, stdout, stderr io.Writer) int {
+	path, asJSON, ok := astDumpFlags("ast", args, stderr)
+	if !ok {
+		return exitUsage
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "ast: %v\n", err)
+		return exitUsage
+	}
+
+	parser := NewParser(New(string(content)))
+	program := parser.ParseProgram()
+	if diagnostics := parser.Diagnostics(); len(diagnostics) > 0 {
+		for _, d := range diagnostics {
+			fmt.Fprintf(stderr, "%s:%s\n", path, d)
+		}
+		return exitParseError
+	}
+
+	if asJSON {
+		return writeASTJSON(stdout, stderr, map[string]interface{}{"version": ASTSchemaVersion, "program": nodeJSON(program)})
+	}
+	printASTNode(stdout, program, 0)
+	return exitOK
+}
+
+// writeASTJSON writes v as indented JSON
+func writeASTJSON(stdout, stderr io.Writer, v interface{}) int {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// printASTNode prints a node described by getStatementType or
+// getExpressionType, followed by its children indented one level deeper
+func printASTNode(w io.Writer, node Node, depth int) {
+	var desc string
+	switch n := node.(type) {
+	case *Program:
+		desc = fmt.Sprintf("Program (%d statements)", len(n.Statements))
+	case *ExpressionStatement:
+		// the description already names the expression, so continue with
+		// the expression's children
+		pos := n.Token
+		fmt.Fprintf(w, "%s%s [%d:%d]\n", strings.Repeat("  ", depth), getStatementType(n), pos.Line, pos.Column)
+		for _, child := range astChildren(n.Expression) {
+			printASTNode(w, child, depth+1)
+		}
+		return
+	case Statement:
+		desc = getStatementType(n)
+	case Expression:
+		desc = getExpressionType(n)
+	}
+
+	if pos := nodeToken(node); pos.Line > 0 {
+		desc += fmt.Sprintf(" [%d:%d]", pos.Line, pos.Column)
+	}
+	fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", depth), desc)
+	for _, child := range astChildren(node) {
+		printASTNode(w, child, depth+1)
+	}
+}
+
+// astChildren returns the direct children of a node in source order,
+// skipping absent optional ones
+func astChildren(node Node) []Node {
+	var children []Node
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			switch n := n.(type) {
+			case nil:
+				continue
+			case *BlockStatement:
+				if n == nil {
+					continue
+				}
+			case *Identifier:
+				if n == nil {
+					continue
+				}
+			}
+			children = append(children, n)
+		}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *LetStatement:
+		add(n.Value)
+	case *FunctionStatement:
+		for i, param := range n.Parameters {
+			add(param)
+			if i < len(n.Defaults) && n.Defaults[i] != nil {
+				add(n.Defaults[i])
+			}
+		}
+		add(n.Rest, n.Body)
+	case *ReturnStatement:
+		add(n.ReturnValue)
+	case *IfStatement:
+		add(n.Condition, n.Consequence, n.Alternative)
+	case *ThrowStatement:
+		add(n.Value)
+	case *TryStatement:
+		add(n.Block, n.CatchParam, n.Catch, n.Finally)
+	case *ExpressionStatement:
+		add(n.Expression)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *PrefixExpression:
+		add(n.Right)
+	case *InfixExpression:
+		add(n.Left, n.Right)
+	case *CallExpression:
+		add(n.Function)
+		for _, arg := range n.Arguments {
+			add(arg)
+		}
+	case *SpawnExpression:
+		add(n.Call)
+	case *PropertyExpression:
+		add(n.Object)
+	case *PropagateExpression:
+		add(n.Value)
+	case *NamedArgument:
+		add(n.Value)
+	case *IndexExpression:
+		add(n.Left, n.Index)
+	}
+	return children
+}
+
+// nodeToken returns the token a node was parsed from
+func nodeToken(node Node) Token {
+	switch n := node.(type) {
+	case Statement:
+		return statementToken(n)
+	case *Identifier:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *DecimalLiteral:
+		return n.Token
+	case *BooleanLiteral:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *InfixExpression:
+		return n.Token
+	case *CallExpression:
+		return n.Token
+	case *SpawnExpression:
+		return n.Token
+	case *PropertyExpression:
+		return n.Token
+	case *PropagateExpression:
+		return n.Token
+	case *NamedArgument:
+		return n.Token
+	case *IndexExpression:
+		return n.Token
+	}
+	return Token{}
+}
+
+// nodeJSON converts a node to its schema representation. A nil node, or a
+// typed nil such as an absent else block, becomes null.
+func nodeJSON(node Node) interface{} {
+	obj := func(typ string, tok Token, fields map[string]interface{}) interface{} {
+		fields["type"] = typ
+		fields["line"] = tok.Line
+		fields["column"] = tok.Column
+		return fields
+	}
+	list := func(n int, at func(i int) Node) []interface{} {
+		out := make([]interface{}, n)
+		for i := range out {
+			out[i] = nodeJSON(at(i))
+		}
+		return out
+	}
+	statements := func(stmts []Statement) []interface{} {
+		return list(len(stmts), func(i int) Node { return stmts[i] })
+	}
+	expressions := func(exprs []Expression) []interface{} {
+		return list(len(exprs), func(i int) Node { return exprs[i] })
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return map[string]interface{}{"type": "Program", "statements": statements(n.Statements)}
+	case *LetStatement:
+		return obj("LetStatement", n.Token, map[string]interface{}{"name": nodeJSON(n.Name), "value": nodeJSON(n.Value)})
+	case *FunctionStatement:
+		params := list(len(n.Parameters), func(i int) Node { return n.Parameters[i] })
+		defaults := make([]interface{}, len(n.Parameters))
+		for i := range defaults {
+			if i < len(n.Defaults) {
+				defaults[i] = nodeJSON(n.Defaults[i])
+			}
+		}
+		return obj("FunctionStatement", n.Token, map[string]interface{}{
+			"doc":        n.Doc,
+			"name":       nodeJSON(n.Name),
+			"parameters": params,
+			"defaults":   defaults,
+			"rest":       nodeJSON(n.Rest),
+			"body":       nodeJSON(n.Body),
+		})
+	case *ReturnStatement:
+		return obj("ReturnStatement", n.Token, map[string]interface{}{"returnValue": nodeJSON(n.ReturnValue)})
+	case *IfStatement:
+		return obj("IfStatement", n.Token, map[string]interface{}{
+			"condition":   nodeJSON(n.Condition),
+			"consequence": nodeJSON(n.Consequence),
+			"alternative": nodeJSON(n.Alternative),
+		})
+	case *ThrowStatement:
+		return obj("ThrowStatement", n.Token, map[string]interface{}{"value": nodeJSON(n.Value)})
+	case *TryStatement:
+		return obj("TryStatement", n.Token, map[string]interface{}{
+			"block":      nodeJSON(n.Block),
+			"catchParam": nodeJSON(n.CatchParam),
+			"catch":      nodeJSON(n.Catch),
+			"finally":    nodeJSON(n.Finally),
+		})
+	case *ExpressionStatement:
+		return obj("ExpressionStatement", n.Token, map[string]interface{}{"expression": nodeJSON(n.Expression)})
+	case *BlockStatement:
+		if n == nil {
+			return nil
+		}
+		return obj("BlockStatement", n.Token, map[string]interface{}{
+			"statements": statements(n.Statements),
+			"endLine":    n.RBrace.Line,
+			"endColumn":  n.RBrace.Column,
+		})
+	case *Identifier:
+		if n == nil {
+			return nil
+		}
+		return obj("Identifier", n.Token, map[string]interface{}{"value": n.Value})
+	case *IntegerLiteral:
+		if n.Big != nil {
+			return obj("IntegerLiteral", n.Token, map[string]interface{}{"value": json.Number(n.Big.String())})
+		}
+		return obj("IntegerLiteral", n.Token, map[string]interface{}{"value": n.Value})
+	case *StringLiteral:
+		return obj("StringLiteral", n.Token, map[string]interface{}{"value": n.Value})
+	case *DecimalLiteral:
+		// a string, as JSON numbers are read as floats
+		return obj("DecimalLiteral", n.Token, map[string]interface{}{"value": n.Value.Inspect()})
+	case *BooleanLiteral:
+		return obj("BooleanLiteral", n.Token, map[string]interface{}{"value": n.Value})
+	case *PrefixExpression:
+		return obj("PrefixExpression", n.Token, map[string]interface{}{"operator": n.Operator, "right": nodeJSON(n.Right)})
+	case *InfixExpression:
+		return obj("InfixExpression", n.Token, map[string]interface{}{
+			"left":     nodeJSON(n.Left),
+			"operator": n.Operator,
+			"right":    nodeJSON(n.Right),
+		})
+	case *CallExpression:
+		if n == nil {
+			return nil
+		}
+		return obj("CallExpression", n.Token, map[string]interface{}{
+			"function":  nodeJSON(n.Function),
+			"arguments": expressions(n.Arguments),
+		})
+	case *SpawnExpression:
+		return obj("SpawnExpression", n.Token, map[string]interface{}{"call": nodeJSON(n.Call)})
+	case *PropertyExpression:
+		return obj("PropertyExpression", n.Token, map[string]interface{}{
+			"object":   nodeJSON(n.Object),
+			"property": nodeJSON(n.Property),
+		})
+	case *PropagateExpression:
+		return obj("PropagateExpression", n.Token, map[string]interface{}{"value": nodeJSON(n.Value)})
+	case *NamedArgument:
+		return obj("NamedArgument", n.Token, map[string]interface{}{"name": nodeJSON(n.Name), "value": nodeJSON(n.Value)})
+	case *IndexExpression:
+		return obj("IndexExpression", n.Token, map[string]interface{}{"left": nodeJSON(n.Left), "index": nodeJSON(n.Index)})
+	}
+	return nil
+}
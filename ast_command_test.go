@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// everyNodeProgram uses each kind of AST node at least once
+const everyNodeProgram = `// Greets someone.
+func greet(name, greeting = "Hi", ...rest) {
+    if (!false) {
+        return greeting + name + rest[0];
+    } else {
+        return;
+    }
+}
+func half(n) {
+    try {
+        throw "odd";
+    } catch (e) {
+        return e.message;
+    } finally {
+        ok(n)?;
+    }
+}
+let x = 1;
+greet(name: x);
+await(spawn half(4));
+`
+
+// writeTinyFile writes a source file into a temporary directory
+func writeTinyFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "prog.tiny")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunASTText(t *testing.T) {
+	path := writeTinyFile(t, "let x = 1 + 2;\nif (x > 2) { print(x); }\n")
+	var stdout, stderr bytes.Buffer
+	if code := runAST([]string{path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	expected := `Program (2 statements)
+  Let Statement (var: x) [1:1]
+    Infix (+) [1:11]
+      Integer (1) [1:9]
+      Integer (2) [1:13]
+  If Statement [2:1]
+    Infix (>) [2:7]
+      Identifier (x) [2:5]
+      Integer (2) [2:9]
+    Block Statement (1 statements) [2:12]
+      Expression Statement (Call (1 args)) [2:14]
+        Identifier (print) [2:14]
+        Identifier (x) [2:20]
+`
+	if stdout.String() != expected {
+		t.Errorf("wrong tree.\nexpected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+}
+
+func TestRunASTJSON(t *testing.T) {
+	path := writeTinyFile(t, everyNodeProgram)
+	var stdout, stderr bytes.Buffer
+	if code := runAST([]string{"--format=json", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	var doc struct {
+		Version int                    `json:"version"`
+		Program map[string]interface{} `json:"program"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	if doc.Version != ASTSchemaVersion {
+		t.Errorf("wrong version %d", doc.Version)
+	}
+
+	// every node names its type and, apart from the program, its position
+	seen := map[string]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, el := range v {
+				walk(el)
+			}
+		case map[string]interface{}:
+			typ, _ := v["type"].(string)
+			seen[typ] = true
+			if line, _ := v["line"].(float64); typ != "Program" && line < 1 {
+				t.Errorf("%s has no position: %v", typ, v)
+			}
+			for _, field := range v {
+				walk(field)
+			}
+		}
+	}
+	walk(doc.Program)
+
+	var types []string
+	for typ := range seen {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	expected := "BlockStatement BooleanLiteral CallExpression ExpressionStatement FunctionStatement " +
+		"Identifier IfStatement IndexExpression InfixExpression IntegerLiteral LetStatement NamedArgument " +
+		"PrefixExpression Program PropagateExpression PropertyExpression ReturnStatement SpawnExpression " +
+		"StringLiteral ThrowStatement TryStatement"
+	if strings.Join(types, " ") != expected {
+		t.Errorf("wrong node types.\nexpected: %s\ngot:      %s", expected, strings.Join(types, " "))
+	}
+
+	fn := doc.Program["statements"].([]interface{})[0].(map[string]interface{})
+	if fn["doc"] != "Greets someone." || len(fn["defaults"].([]interface{})) != 2 || fn["defaults"].([]interface{})[0] != nil {
+		t.Errorf("wrong function node: %v", fn)
+	}
+}
+
+func TestRunASTParseError(t *testing.T) {
+	path := writeTinyFile(t, "let = 1;\n")
+	var stdout, stderr bytes.Buffer
+	if code := runAST([]string{path}, &stdout, &stderr); code != exitParseError {
+		t.Errorf("expected exit code %d, got %d", exitParseError, code)
+	}
+	if stdout.Len() != 0 || !strings.HasPrefix(stderr.String(), path+":1:") {
+		t.Errorf("expected a diagnostic on stderr, got %q / %q", stdout.String(), stderr.String())
+	}
+
+	if code := runAST([]string{"-format", "xml", path}, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown format, got %d", code)
+	}
+}
+
+func TestRunTokens(t *testing.T) {
+	path := writeTinyFile(t, "let s = \"a b\";")
+	var stdout, stderr bytes.Buffer
+	if code := runTokens([]string{path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "1:1\tLET        \"let\"\n1:5\tIDENT      \"s\"\n1:7\t=          \"=\"\n" +
+		"1:9\tSTRING     \"a b\"\n1:14\t;          \";\"\n1:15\tEOF        \"\"\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong tokens.\nexpected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runTokens([]string{"-format=json", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var doc struct {
+		Version int `json:"version"`
+		Tokens  []struct {
+			Type    string `json:"type"`
+			Literal string `json:"literal"`
+			Line    int    `json:"line"`
+			Column  int    `json:"column"`
+		} `json:"tokens"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != ASTSchemaVersion || len(doc.Tokens) != 6 || doc.Tokens[3].Type != "STRING" ||
+		doc.Tokens[3].Literal != "a b" || doc.Tokens[3].Column != 9 {
+		t.Errorf("wrong token JSON: %+v", doc)
+	}
+}
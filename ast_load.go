@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ASTLoadError lists everything wrong with a JSON AST. Each message starts
+// with the path of the offending value, such as
+// "program.statements[2].value.right".
+type ASTLoadError struct {
+	Errors []string
+}
+
+func (e *ASTLoadError) Error() string {
+	return "invalid AST: " + strings.Join(e.Errors, "; ")
+}
+
+// LoadProgramJSON builds a program from the JSON written by
+// "tinylang ast -format json", so that generated programs can be evaluated
+// without printing and re-parsing source text. Tokens the schema does not
+// store, such as keywords and delimiters, are reconstructed. Positions and
+// optional children may be left out, so nodes without a "line" are at 0.
+func LoadProgramJSON(data []byte) (*Program, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, &ASTLoadError{Errors: []string{err.Error()}}
+	}
+
+	l := &astLoader{}
+	root := &astObject{l: l, path: "", fields: doc}
+	if version, ok := root.integer("version"); ok && version != ASTSchemaVersion {
+		l.errorf("version", "unsupported schema version %d, want %d", version, ASTSchemaVersion)
+		return nil, &ASTLoadError{Errors: l.errors}
+	}
+
+	var program *Program
+	if obj := root.object("program", "Program"); obj != nil {
+		program = &Program{Statements: obj.statements("statements")}
+	}
+	if len(l.errors) > 0 {
+		return nil, &ASTLoadError{Errors: l.errors}
+	}
+	return program, nil
+}
+
+// astLoader collects the validation errors of one document
+type astLoader struct {
+	errors []string
+}
+
+func (l *astLoader) errorf(path, format string, a ...interface{}) {
+	l.errors = append(l.errors, path+": "+fmt.Sprintf(format, a...))
+}
+
+// astObject is a JSON object being converted to a node
+type astObject struct {
+	l      *astLoader
+	path   string
+	fields map[string]interface{}
+}
+
+// fieldPath returns the path of one of the object's fields
+func (o *astObject) fieldPath(name string) string {
+	if o.path == "" {
+		return name
+	}
+	return o.path + "." + name
+}
+
+// get returns a field, reporting it when missing
+func (o *astObject) get(name string) (interface{}, bool) {
+	v, ok := o.fields[name]
+	if !ok {
+		o.l.errorf(o.fieldPath(name), "missing field")
+	}
+	return v, ok
+}
+
+func (o *astObject) str(name string) string {
+	v, ok := o.get(name)
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		o.l.errorf(o.fieldPath(name), "expected a string, got %s", jsonKind(v))
+	}
+	return s
+}
+
+func (o *astObject) integer(name string) (int64, bool) {
+	v, ok := o.get(name)
+	if !ok {
+		return 0, false
+	}
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+	}
+	o.l.errorf(o.fieldPath(name), "expected an integer, got %s", jsonKind(v))
+	return 0, false
+}
+
+func (o *astObject) boolean(name string) bool {
+	v, ok := o.get(name)
+	if !ok {
+		return false
+	}
+	b, ok := v.(bool)
+	if !ok {
+		o.l.errorf(o.fieldPath(name), "expected a boolean, got %s", jsonKind(v))
+	}
+	return b
+}
+
+// optionalInteger returns an integer field, or 0 when it is missing or null
+func (o *astObject) optionalInteger(name string) int64 {
+	if o.optional(name) {
+		return 0
+	}
+	i, _ := o.integer(name)
+	return i
+}
+
+// optionalStr returns a string field, or "" when it is missing or null
+func (o *astObject) optionalStr(name string) string {
+	if o.optional(name) {
+		return ""
+	}
+	return o.str(name)
+}
+
+// token rebuilds the node's token from its position. Positions are
+// optional, so that generated programs need not make them up.
+func (o *astObject) token(tokenType TokenType, literal string) Token {
+	return Token{Type: tokenType, Literal: literal, Line: int(o.optionalInteger("line")), Column: int(o.optionalInteger("column"))}
+}
+
+// object returns a field holding a node object. A null field is reported
+// unless the child is optional; either way nil is returned.
+func (o *astObject) object(name string, types ...string) *astObject {
+	v, ok := o.get(name)
+	if !ok {
+		return nil
+	}
+	return o.l.object(v, o.fieldPath(name), types...)
+}
+
+// object checks that v is a node object. When types are given, the node
+// must be one of them.
+func (l *astLoader) object(v interface{}, path string, types ...string) *astObject {
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		l.errorf(path, "expected a node, got %s", jsonKind(v))
+		return nil
+	}
+	typ, _ := fields["type"].(string)
+	if len(types) > 0 {
+		found := false
+		for _, t := range types {
+			found = found || t == typ
+		}
+		if !found {
+			l.errorf(path, "expected %s, got %q", strings.Join(types, " or "), typ)
+			return nil
+		}
+	}
+	return &astObject{l: l, path: path, fields: fields}
+}
+
+// optional reports whether the field is missing or null
+func (o *astObject) optional(name string) bool {
+	v, ok := o.fields[name]
+	return !ok || v == nil
+}
+
+// list returns the elements of an array field
+func (o *astObject) list(name string) []interface{} {
+	v, ok := o.get(name)
+	if !ok {
+		return nil
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		o.l.errorf(o.fieldPath(name), "expected an array, got %s", jsonKind(v))
+	}
+	return items
+}
+
+func (o *astObject) identifier(name string) *Identifier {
+	if obj := o.object(name, "Identifier"); obj != nil {
+		return obj.node().(*Identifier)
+	}
+	return nil
+}
+
+func (o *astObject) optionalIdentifier(name string) *Identifier {
+	if o.optional(name) {
+		return nil
+	}
+	return o.identifier(name)
+}
+
+func (o *astObject) block(name string) *BlockStatement {
+	if obj := o.object(name, "BlockStatement"); obj != nil {
+		return obj.node().(*BlockStatement)
+	}
+	return nil
+}
+
+func (o *astObject) optionalBlock(name string) *BlockStatement {
+	if o.optional(name) {
+		return nil
+	}
+	return o.block(name)
+}
+
+func (o *astObject) expression(name string) Expression {
+	v, ok := o.get(name)
+	if !ok {
+		return nil
+	}
+	return o.l.expression(v, o.fieldPath(name))
+}
+
+func (o *astObject) optionalExpression(name string) Expression {
+	if o.optional(name) {
+		return nil
+	}
+	return o.expression(name)
+}
+
+// expression converts v, which must be an expression node
+func (l *astLoader) expression(v interface{}, path string) Expression {
+	obj := l.object(v, path)
+	if obj == nil {
+		return nil
+	}
+	node := obj.node()
+	if node == nil {
+		return nil
+	}
+	expr, ok := node.(Expression)
+	if !ok {
+		l.errorf(path, "expected an expression, got %q", obj.fields["type"])
+	}
+	return expr
+}
+
+func (o *astObject) expressions(name string) []Expression {
+	items := o.list(name)
+	exprs := make([]Expression, 0, len(items))
+	for i, item := range items {
+		if expr := o.l.expression(item, fmt.Sprintf("%s[%d]", o.fieldPath(name), i)); expr != nil {
+			exprs = append(exprs, expr)
+		}
+	}
+	return exprs
+}
+
+func (o *astObject) statements(name string) []Statement {
+	items := o.list(name)
+	stmts := make([]Statement, 0, len(items))
+	for i, item := range items {
+		path := fmt.Sprintf("%s[%d]", o.fieldPath(name), i)
+		obj := o.l.object(item, path)
+		if obj == nil {
+			continue
+		}
+		node := obj.node()
+		if node == nil {
+			continue
+		}
+		if stmt, ok := node.(Statement); ok {
+			stmts = append(stmts, stmt)
+		} else {
+			o.l.errorf(path, "expected a statement, got %q", obj.fields["type"])
+		}
+	}
+	return stmts
+}
+
+// node converts the object to the AST node named by its "type" field. It
+// returns nil, with an error recorded, for unknown types.
+func (o *astObject) node() Node {
+	typ := o.str("type")
+	switch typ {
+	case "LetStatement":
+		return &LetStatement{Token: o.token(LET, "let"), Name: o.identifier("name"), Value: o.expression("value")}
+	case "FunctionStatement":
+		return o.functionStatement()
+	case "ReturnStatement":
+		return &ReturnStatement{Token: o.token(RETURN, "return"), ReturnValue: o.optionalExpression("returnValue")}
+	case "IfStatement":
+		return &IfStatement{
+			Token:       o.token(IF, "if"),
+			Condition:   o.expression("condition"),
+			Consequence: o.block("consequence"),
+			Alternative: o.optionalBlock("alternative"),
+		}
+	case "ThrowStatement":
+		return &ThrowStatement{Token: o.token(THROW, "throw"), Value: o.expression("value")}
+	case "TryStatement":
+		stmt := &TryStatement{
+			Token:      o.token(TRY, "try"),
+			Block:      o.block("block"),
+			CatchParam: o.optionalIdentifier("catchParam"),
+			Catch:      o.optionalBlock("catch"),
+			Finally:    o.optionalBlock("finally"),
+		}
+		if stmt.Catch == nil && stmt.Finally == nil {
+			o.l.errorf(o.path, "try statement needs a catch or finally block")
+		}
+		if (stmt.CatchParam == nil) != (stmt.Catch == nil) {
+			o.l.errorf(o.path, "catchParam and catch must both be set or both be null")
+		}
+		return stmt
+	case "ExpressionStatement":
+		expr := o.expression("expression")
+		first := nodeToken(expr)
+		return &ExpressionStatement{Token: o.token(first.Type, first.Literal), Expression: expr}
+	case "BlockStatement":
+		block := &BlockStatement{Token: o.token(LBRACE, "{"), Statements: o.statements("statements")}
+		block.RBrace = Token{Type: RBRACE, Literal: "}", Line: int(o.optionalInteger("endLine")), Column: int(o.optionalInteger("endColumn"))}
+		return block
+	case "Identifier":
+		value := o.str("value")
+		if !isIdentifierName(value) {
+			o.l.errorf(o.fieldPath("value"), "%q is not a valid identifier", value)
+		}
+		return &Identifier{Token: o.token(IDENT, value), Value: value}
+	case "IntegerLiteral":
+		// Literals past 64 bits are kept for runs with BigInts
+		if n, ok := o.fields["value"].(json.Number); ok {
+			if value, ok := new(big.Int).SetString(string(n), 10); ok && !value.IsInt64() {
+				return &IntegerLiteral{Token: o.token(INT, value.String()), Big: value}
+			}
+		}
+		value, _ := o.integer("value")
+		return &IntegerLiteral{Token: o.token(INT, fmt.Sprint(value)), Value: value}
+	case "StringLiteral":
+		value := o.str("value")
+		return &StringLiteral{Token: o.token(STRING, value), Value: value}
+	case "DecimalLiteral":
+		value := o.str("value")
+		d, ok := parseDecimal(value)
+		if !ok {
+			o.l.errorf(o.fieldPath("value"), "%q is not a decimal", value)
+		}
+		return &DecimalLiteral{Token: o.token(DECIMAL, value+"d"), Value: d}
+	case "BooleanLiteral":
+		if o.boolean("value") {
+			return &BooleanLiteral{Token: o.token(TRUE, "true"), Value: true}
+		}
+		return &BooleanLiteral{Token: o.token(FALSE, "false"), Value: false}
+	case "PrefixExpression":
+		operator := o.str("operator")
+		if operator != "!" && operator != "-" {
+			o.l.errorf(o.fieldPath("operator"), "unknown prefix operator %q", operator)
+		}
+		return &PrefixExpression{Token: o.token(LookupOperator(operator), operator), Operator: operator, Right: o.expression("right")}
+	case "InfixExpression":
+		operator := o.str("operator")
+		tokenType := LookupOperator(operator)
+		if prec, ok := precedences[tokenType]; !ok || prec >= CALL {
+			o.l.errorf(o.fieldPath("operator"), "unknown infix operator %q", operator)
+		}
+		return &InfixExpression{
+			Token:    o.token(tokenType, operator),
+			Left:     o.expression("left"),
+			Operator: operator,
+			Right:    o.expression("right"),
+		}
+	case "CallExpression":
+		return &CallExpression{Token: o.token(LPAREN, "("), Function: o.expression("function"), Arguments: o.expressions("arguments")}
+	case "SpawnExpression":
+		spawn := &SpawnExpression{Token: o.token(SPAWN, "spawn")}
+		if obj := o.object("call", "CallExpression"); obj != nil {
+			spawn.Call = obj.node().(*CallExpression)
+		}
+		return spawn
+	case "PropertyExpression":
+		return &PropertyExpression{Token: o.token(DOT, "."), Object: o.expression("object"), Property: o.identifier("property")}
+	case "PropagateExpression":
+		return &PropagateExpression{Token: o.token(QUESTION, "?"), Value: o.expression("value")}
+	case "NamedArgument":
+		name := o.identifier("name")
+		arg := &NamedArgument{Name: name, Value: o.expression("value")}
+		if name != nil {
+			arg.Token = name.Token
+		}
+		return arg
+	case "IndexExpression":
+		return &IndexExpression{Token: o.token(LBRACKET, "["), Left: o.expression("left"), Index: o.expression("index")}
+	case "":
+		return nil
+	}
+	o.l.errorf(o.fieldPath("type"), "unknown node type %q", typ)
+	return nil
+}
+
+// functionStatement converts a FunctionStatement object, whose defaults
+// list has one entry, possibly null, per parameter
+func (o *astObject) functionStatement() *FunctionStatement {
+	fn := &FunctionStatement{
+		Token:      o.token(FUNCTION, "func"),
+		Doc:        o.optionalStr("doc"),
+		Name:       o.identifier("name"),
+		Parameters: []*Identifier{},
+		Defaults:   []Expression{},
+		Rest:       o.optionalIdentifier("rest"),
+		Body:       o.block("body"),
+	}
+
+	params := o.list("parameters")
+	for i, item := range params {
+		if obj := o.l.object(item, fmt.Sprintf("%s[%d]", o.fieldPath("parameters"), i), "Identifier"); obj != nil {
+			fn.Parameters = append(fn.Parameters, obj.node().(*Identifier))
+		}
+	}
+
+	defaults := o.list("defaults")
+	if defaults != nil && len(defaults) != len(params) {
+		o.l.errorf(o.fieldPath("defaults"), "got %d defaults for %d parameters", len(defaults), len(params))
+	}
+	hasDefault := false
+	for i, item := range defaults {
+		var def Expression
+		if item != nil {
+			def = o.l.expression(item, fmt.Sprintf("%s[%d]", o.fieldPath("defaults"), i))
+			hasDefault = true
+		} else if hasDefault {
+			o.l.errorf(fmt.Sprintf("%s[%d]", o.fieldPath("defaults"), i), "parameter without default follows parameter with default")
+		}
+		fn.Defaults = append(fn.Defaults, def)
+	}
+	return fn
+}
+
+// LookupOperator returns the token type of an operator such as "+" or
+// "&&", or ILLEGAL
+func LookupOperator(op string) TokenType {
+	for tokenType := PLUS; tokenType <= NOT; tokenType++ {
+		if tokenTypeNames[tokenType] == op {
+			return tokenType
+		}
+	}
+	return ILLEGAL
+}
+
+// jsonKind names the JSON type of a decoded value for error messages
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	}
+	return "an object"
+}
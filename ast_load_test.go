@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// programJSON encodes a program the way "tinylang ast -format json" does
+func programJSON(t *testing.T, program *Program) []byte {
+	data, err := json.Marshal(map[string]interface{}{"version": ASTSchemaVersion, "program": nodeJSON(program)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// printProgram formats a program, without comments
+func printProgram(program *Program) string {
+	p := &printer{}
+	p.printStatements(program.Statements, 0)
+	return p.out.String()
+}
+
+func TestASTRoundTrip(t *testing.T) {
+	sources := map[string]string{"everyNodeProgram": everyNodeProgram}
+	examples, _ := filepath.Glob(filepath.Join("examples", "*.tiny"))
+	if len(examples) == 0 {
+		t.Fatal("no examples found")
+	}
+	for _, path := range examples {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[path] = string(content)
+	}
+
+	for name, source := range sources {
+		p := NewParser(New(source))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		data := programJSON(t, program)
+		loaded, err := LoadProgramJSON(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if loaded.String() != program.String() {
+			t.Errorf("%s: String() differs after the round trip.\nexpected:\n%s\ngot:\n%s", name, program.String(), loaded.String())
+		}
+		if again := programJSON(t, loaded); string(again) != string(data) {
+			t.Errorf("%s: JSON differs after the round trip", name)
+		}
+		if printProgram(loaded) != printProgram(program) {
+			t.Errorf("%s: formatted source differs after the round trip", name)
+		}
+	}
+}
+
+func TestLoadProgramJSONEval(t *testing.T) {
+	p := NewParser(New("func list(...items) { return items; } func fact(n) { if (n < 2) { return 1; } return n * fact(n - 1); } list(fact(5), -2, !true);"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	loaded, err := LoadProgramJSON(programJSON(t, program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := Eval(loaded, NewEnvironment()); result.Inspect() != "[120, -2, false]" {
+		t.Errorf("wrong result %s", result.Inspect())
+	}
+
+	p = NewParser(New("99999999999999999999 + 1;"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if loaded, err = LoadProgramJSON(programJSON(t, program)); err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnvironment()
+	env.rt.SetOverflow(OverflowBigInt)
+	if result := Eval(loaded, env); result.Inspect() != "100000000000000000000" {
+		t.Errorf("wrong result for a literal past 64 bits %s", result.Inspect())
+	}
+}
+
+func TestLoadProgramJSONWithoutPositions(t *testing.T) {
+	input := `{"version": 1, "program": {"type": "Program", "statements": [
+		{"type": "FunctionStatement", "name": {"type": "Identifier", "value": "double"},
+			"parameters": [{"type": "Identifier", "value": "x"}], "defaults": [null],
+			"body": {"type": "BlockStatement", "statements": [
+				{"type": "ReturnStatement", "returnValue": {"type": "InfixExpression", "operator": "*",
+					"left": {"type": "Identifier", "value": "x"}, "right": {"type": "IntegerLiteral", "value": 2}}}]}},
+		{"type": "ExpressionStatement", "expression": {"type": "CallExpression",
+			"function": {"type": "Identifier", "value": "double"}, "arguments": [{"type": "IntegerLiteral", "value": 21}]}}]}}`
+
+	program, err := LoadProgramJSON([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := program.Statements[0].(*FunctionStatement)
+	if fn.Token.Line != 0 || fn.Doc != "" || fn.Rest != nil {
+		t.Errorf("wrong defaults: line %d, doc %q, rest %v", fn.Token.Line, fn.Doc, fn.Rest)
+	}
+	if result := Eval(program, NewEnvironment()); result.Inspect() != "42" {
+		t.Errorf("wrong result %s", result.Inspect())
+	}
+}
+
+func TestLoadProgramJSONErrors(t *testing.T) {
+	ident := `{"type": "Identifier", "line": 1, "column": 5, "value": "x"}`
+	let := func(value string) string {
+		return `{"version": 1, "program": {"type": "Program", "statements": [{"type": "LetStatement", "line": 1, "column": 1, "name": ` +
+			ident + `, "value": ` + value + `}]}}`
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1]`, "cannot unmarshal"},
+		{`{"version": 2, "program": {}}`, "version: unsupported schema version 2, want 1"},
+		{`{"program": {"type": "Program", "statements": []}}`, "version: missing field"},
+		{`{"version": 1, "program": {"type": "LetStatement"}}`, `program: expected Program, got "LetStatement"`},
+		{let(`null`), "program.statements[0].value: expected a node, got null"},
+		{let(`{"type": "Widget", "line": 1, "column": 9}`), `program.statements[0].value.type: unknown node type "Widget"`},
+		{let(`{"type": "IntegerLiteral", "line": 1, "column": 9, "value": "7"}`), "program.statements[0].value.value: expected an integer, got a string"},
+		{let(`{"type": "IntegerLiteral", "line": "1", "column": 9, "value": 7}`), "program.statements[0].value.line: expected an integer, got a string"},
+		{let(`{"type": "InfixExpression", "line": 1, "column": 9, "operator": "%", "left": ` + ident + `, "right": ` + ident + `}`),
+			`program.statements[0].value.operator: unknown infix operator "%"`},
+		{let(`{"type": "Identifier", "line": 1, "column": 9, "value": "let"}`), `program.statements[0].value.value: "let" is not a valid identifier`},
+		{let(`{"type": "BlockStatement", "line": 1, "column": 9, "statements": [], "endLine": 1, "endColumn": 10}`),
+			`program.statements[0].value: expected an expression, got "BlockStatement"`},
+		{`{"version": 1, "program": {"type": "Program", "statements": [` + ident + `]}}`,
+			`program.statements[0]: expected a statement, got "Identifier"`},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadProgramJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s:\nexpected error containing %q, got %q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestRunFromAST(t *testing.T) {
+	source := writeTinyFile(t, "func double(x) { return x * 2; }\nprint(double(21));\n")
+	var astOut, stderr strings.Builder
+	if code := runAST([]string{"-format", "json", source}, &astOut, &stderr); code != 0 {
+		t.Fatalf("ast failed: %s", stderr.String())
+	}
+	path := filepath.Join(t.TempDir(), "prog.json")
+	if err := os.WriteFile(path, []byte(astOut.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout strings.Builder
+	if code := runRun([]string{"-ast", path}, nil, &stdout, &stderr); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, stdout.String())
+	}
+	if stdout.String() != "42\nnull\n" {
+		t.Errorf("wrong output %q", stdout.String())
+	}
+}
@@ -18,6 +18,7 @@ func BenchmarkLexer(b *testing.B) {
 		return false;
 	}`
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		lexer := New(input)
@@ -44,6 +45,7 @@ func BenchmarkParser(b *testing.B) {
 		return false;
 	}`
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		lexer := New(input)
@@ -66,6 +68,7 @@ func BenchmarkEvaluator(b *testing.B) {
 	parser := NewParser(lexer)
 	program := parser.ParseProgram()
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		env := NewEnvironment()
@@ -73,7 +76,8 @@ func BenchmarkEvaluator(b *testing.B) {
 	}
 }
 
-// BenchmarkFibonacci benchmarks a recursive function
+// BenchmarkFibonacci benchmarks a recursive function, whose cost is
+// mostly the calls' scopes and the integers they produce
 func BenchmarkFibonacci(b *testing.B) {
 	input := `func fib(n) {
 		if (n <= 1) {
@@ -88,6 +92,7 @@ func BenchmarkFibonacci(b *testing.B) {
 	parser := NewParser(lexer)
 	program := parser.ParseProgram()
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		env := NewEnvironment()
@@ -123,6 +128,7 @@ func BenchmarkComplexProgram(b *testing.B) {
 	parser := NewParser(lexer)
 	program := parser.ParseProgram()
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		env := NewEnvironment()
@@ -144,6 +150,7 @@ func BenchmarkStringConcatenation(b *testing.B) {
 	parser := NewParser(lexer)
 	program := parser.ParseProgram()
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		env := NewEnvironment()
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// OverflowMode says what integer arithmetic does with a result that does
+// not fit in 64 bits. Integers never silently wrap around.
+type OverflowMode int
+
+const (
+	OverflowError  OverflowMode = iota // raise an OverflowError
+	OverflowBigInt                     // continue with an arbitrary-precision BigInt
+)
+
+// String implements flag.Value
+func (m *OverflowMode) String() string {
+	if m != nil && *m == OverflowBigInt {
+		return "bigint"
+	}
+	return "error"
+}
+
+// Set implements flag.Value
+func (m *OverflowMode) Set(s string) error {
+	switch s {
+	case "error":
+		*m = OverflowError
+	case "bigint":
+		*m = OverflowBigInt
+	default:
+		return fmt.Errorf("unknown overflow mode %q, want error or bigint", s)
+	}
+	return nil
+}
+
+// SetOverflow selects what the run does when integer arithmetic overflows
+func (rt *Runtime) SetOverflow(mode OverflowMode) {
+	rt.overflow = mode
+}
+
+// newBigInteger returns v as an Integer if it fits in 64 bits and as a
+// BigInt otherwise
+func newBigInteger(v *big.Int) Object {
+	if v.IsInt64() {
+		return newInteger(v.Int64())
+	}
+	return &BigInt{Value: v}
+}
+
+// toBig returns the value of an Integer or BigInt as a big.Int that must
+// not be modified
+func toBig(obj Object) *big.Int {
+	if b, ok := obj.(*BigInt); ok {
+		return b.Value
+	}
+	return big.NewInt(obj.(*Integer).Value)
+}
+
+// evalBigIntegerLiteral evaluates a literal too large for 64 bits
+func evalBigIntegerLiteral(node *IntegerLiteral, env *Environment) Object {
+	if env.rt.overflow != OverflowBigInt {
+		return newErrorKind(OVERFLOW_ERROR, "integer literal %s does not fit in 64 bits", node.Token.Literal)
+	}
+	return &BigInt{Value: node.Big}
+}
+
+// evalIntegerNegation negates an integer, which overflows for the
+// smallest 64-bit value
+func evalIntegerNegation(right Object, env *Environment) Object {
+	switch right := right.(type) {
+	case *BigInt:
+		return newBigInteger(new(big.Int).Neg(right.Value))
+	case *Integer:
+		if right.Value != math.MinInt64 {
+			return newInteger(-right.Value)
+		}
+		if env.rt.overflow != OverflowBigInt {
+			return newErrorKind(OVERFLOW_ERROR, "integer overflow: -(%d)", right.Value)
+		}
+		return newBigInteger(new(big.Int).Neg(toBig(right)))
+	}
+	return newErrorKind(TYPE_ERROR, "unknown operator: -%s", right.Type())
+}
+
+// checkedIntegerInfix applies an arithmetic operator to 64-bit integers
+// and reports whether the result fits in 64 bits
+func checkedIntegerInfix(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		sum := left + right
+		return sum, (sum > left) == (right > 0)
+	case "-":
+		diff := left - right
+		return diff, (diff < left) == (right > 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return 0, true
+		}
+		product := left * right
+		// MinInt64 * -1 wraps to MinInt64, which the division misses
+		return product, product/right == left && !(right == -1 && left == math.MinInt64)
+	case "/":
+		return left / right, !(left == math.MinInt64 && right == -1)
+	}
+	return 0, false
+}
+
+// integerOverflow handles an arithmetic result that does not fit in 64
+// bits as the run's OverflowMode says
+func integerOverflow(operator string, left, right Object, env *Environment) Object {
+	if env.rt.overflow != OverflowBigInt {
+		return newErrorKind(OVERFLOW_ERROR, "integer overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+	}
+	return evalBigIntInfixExpression(operator, left, right)
+}
+
+// evalBigIntInfixExpression evaluates an operator on integers of which at
+// least one is a BigInt, or whose result overflows 64 bits
+func evalBigIntInfixExpression(operator string, left, right Object) Object {
+	leftVal, rightVal := toBig(left), toBig(right)
+
+	switch operator {
+	case "+":
+		return newBigInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return newBigInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return newBigInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newErrorKind(ZERO_DIVISION_ERROR, "division by zero")
+		}
+		// Quo truncates toward zero, like 64-bit division
+		return newBigInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "<":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newErrorKind(TYPE_ERROR, "unknown operator: %s", operator)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// builtins is the registry of functions provided by the interpreter.
+// It is populated once at package initialization and only read afterwards,
+// so it is safe to share between concurrently running interpreters.
+var builtins = map[string]*Builtin{
+	"len":   {Name: "len", Fn: builtinLen},
+	"str":   {Name: "str", Fn: builtinStr},
+	"int":   {Name: "int", Fn: builtinInt},
+	"print": {Name: "print", Fn: builtinPrint},
+
+	"ok":     {Name: "ok", Fn: builtinOk},
+	"err":    {Name: "err", Fn: builtinErr},
+	"is_ok":  {Name: "is_ok", Fn: builtinIsOk},
+	"is_err": {Name: "is_err", Fn: builtinIsErr},
+}
+
+// lookupBuiltin returns the builtin registered under name
+func lookupBuiltin(name string) (*Builtin, bool) {
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// builtinLen returns the length of a string or array
+func builtinLen(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to len: got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return newInteger(int64(len(arg.Value)))
+	case *Array:
+		return newInteger(int64(len(arg.Elements)))
+	default:
+		return newErrorKind(TYPE_ERROR, "argument to len not supported, got %s", args[0].Type())
+	}
+}
+
+// builtinStr converts any value to its string representation
+func builtinStr(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to str: got=%d, want=1", len(args))
+	}
+
+	if s, ok := args[0].(*String); ok {
+		return s
+	}
+	return &String{Value: args[0].Inspect()}
+}
+
+// builtinInt converts a string, integer or decimal to an integer. Strings that do
+// not hold an integer, or one too large for a run without BigInts,
+// produce an Err value rather than a runtime error.
+func builtinInt(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to int: got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt:
+		return arg
+	case *Decimal:
+		// the fraction is dropped, rounding toward zero
+		value := roundQuo(arg.Value, pow10(arg.Scale), RoundDown)
+		if !value.IsInt64() && env.rt.overflow != OverflowBigInt {
+			return &Err{Error: newErrorKind(OVERFLOW_ERROR, "%s does not fit in 64 bits", arg.Inspect())}
+		}
+		return newBigInteger(value)
+	case *String:
+		value, err := strconv.ParseInt(arg.Value, 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			if env.rt.overflow != OverflowBigInt {
+				return &Err{Error: newErrorKind(OVERFLOW_ERROR, "%s does not fit in 64 bits", arg.Value)}
+			}
+			value, _ := new(big.Int).SetString(arg.Value, 10)
+			return newBigInteger(value)
+		}
+		if err != nil {
+			return &Err{Error: newErrorKind(VALUE_ERROR, "could not parse %q as integer", arg.Value)}
+		}
+		return newInteger(value)
+	default:
+		return newErrorKind(TYPE_ERROR, "argument to int not supported, got %s", args[0].Type())
+	}
+}
+
+// builtinPrint writes its arguments to standard output, separated by spaces
+func builtinPrint(env *Environment, args ...Object) Object {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Inspect()
+	}
+	fmt.Fprintln(env.rt.Output(), values...)
+	return NULL
+}
+
+// builtinOk wraps a value as a successful result
+func builtinOk(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to ok: got=%d, want=1", len(args))
+	}
+	return &Ok{Value: args[0]}
+}
+
+// builtinErr creates an Err value. A string argument becomes the message;
+// an existing Err is returned unchanged.
+func builtinErr(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to err: got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Err:
+		return arg
+	default:
+		return &Err{Error: newErrorKind(THROWN_ERROR, "%s", arg.Inspect())}
+	}
+}
+
+// builtinIsOk reports whether a value is not an Err
+func builtinIsOk(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to is_ok: got=%d, want=1", len(args))
+	}
+	return nativeBoolToPyBoolean(args[0].Type() != ERR_OBJ)
+}
+
+// builtinIsErr reports whether a value is an Err
+func builtinIsErr(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to is_err: got=%d, want=1", len(args))
+	}
+	return nativeBoolToPyBoolean(args[0].Type() == ERR_OBJ)
+}
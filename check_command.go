@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// checkRules are the analyzer rules "tinylang check" enforces: mistakes
+// that would fail at run time, as opposed to the style rules of lint
+var checkRules = []string{RuleUndefined, RuleArity, RuleDuplicateFunction, RuleReturnOutside}
+
+// checkProblem is a diagnostic in one file. Rule is "syntax" for parse
+// errors.
+type checkProblem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// checkSource lexes, parses and analyzes one program without running it
+func checkSource(name string, source []byte) []checkProblem {
+	config := LintConfig{Rules: map[string]bool{}}
+	for _, rule := range checkRules {
+		config.Rules[rule] = true
+	}
+
+	lexer := New(string(source))
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	diagnostics := parser.Diagnostics()
+	if len(diagnostics) == 0 {
+		diagnostics = Lint(program, lexer.Comments(), config)
+	}
+
+	problems := make([]checkProblem, len(diagnostics))
+	for i, d := range diagnostics {
+		problems[i] = checkProblem{File: name, Line: d.Line, Column: d.Column, Rule: d.Rule, Message: d.Message}
+	}
+	return problems
+}
+
+// runCheck implements "tinylang check [-format human|json|github]
+// [file.tiny | dir | - ...]". It analyzes the programs without running
+// them, several files at a time, and reports problems in file order. It
+// returns exitParseError if any file has a syntax error and 1 if any
+// other problem was found.
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "human", "report `format`: human (on stderr), json or github (on stdout)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tinylang check [-format human|json|github] [file.tiny | dir | - ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "human" && *format != "json" && *format != "github" {
+		fmt.Fprintf(stderr, "check: unknown format %q\n", *format)
+		return exitUsage
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, path := range paths {
+		if path == "-" {
+			files = append(files, path)
+			continue
+		}
+		found, err := tinyFiles([]string{path})
+		if err != nil {
+			fmt.Fprintf(stderr, "check: %v\n", err)
+			return exitUsage
+		}
+		files = append(files, found...)
+	}
+
+	// stdin can only be read once, and before the workers start
+	sources := make([][]byte, len(files))
+	names := make([]string, len(files))
+	for i, path := range files {
+		if path != "-" {
+			names[i] = path
+			continue
+		}
+		name, content, err := readSource(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "check: %v\n", err)
+			return exitUsage
+		}
+		names[i], sources[i] = name, content
+	}
+
+	results := make([][]checkProblem, len(files))
+	readErrors := make([]error, len(files))
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if files[i] != "-" {
+					_, sources[i], readErrors[i] = readSource(files[i], nil)
+					if readErrors[i] != nil {
+						continue
+					}
+				}
+				results[i] = checkSource(names[i], sources[i])
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	status := exitOK
+	problems := []checkProblem{}
+	for i, result := range results {
+		if readErrors[i] != nil {
+			fmt.Fprintf(stderr, "check: %v\n", readErrors[i])
+			return exitUsage
+		}
+		for _, p := range result {
+			if p.Rule == "syntax" {
+				status = exitParseError
+			} else if status == exitOK {
+				status = exitRuntimeError
+			}
+		}
+		problems = append(problems, result...)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(problems)
+	case "github":
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "::error file=%s,line=%d,col=%d,title=%s::%s\n",
+				githubPropertyEscaper.Replace(p.File), p.Line, p.Column, p.Rule, githubEscaper.Replace(p.Message))
+		}
+	default:
+		for _, p := range problems {
+			fmt.Fprintf(stderr, "%s:%d:%d: %s (%s)\n", p.File, p.Line, p.Column, p.Message, p.Rule)
+		}
+	}
+	return status
+}
+
+// githubEscaper escapes the message of a GitHub Actions workflow command,
+// and githubPropertyEscaper its property values, which additionally may
+// not contain ':' or ','
+var (
+	githubEscaper         = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; print(x);", nil},
+		{"let unused = 1;", nil},
+		{"print(y);", []string{"1:7: undefined: y (undefined)"}},
+		{"if (len(args) > 0) { print(args[0]); }", nil},
+		{"func add(a, b) { return a + b; } add(1);", []string{"1:34: wrong number of arguments to add: got=1, want=2 (arity)"}},
+		{"func f() { return 1; }\nfunc f() { return 2; }", []string{"2:6: f already declared on line 1 (duplicate-function)"}},
+		{"return 1;", []string{"1:1: return outside function (return-outside-function)"}},
+		{"print(y); // lint:ignore undefined", nil},
+		{"let = 1;", []string{
+			"1:5: expected next token to be IDENT, got = instead (syntax)",
+			"1:5: no prefix parse function for = found (syntax)",
+		}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, p := range checkSource("prog.tiny", []byte(tt.input)) {
+			got = append(got, (Diagnostic{Line: p.Line, Column: p.Column, Rule: p.Rule, Message: p.Message}).String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("check %q wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.tiny":          "let x = 1;\nprint(x, len(args));\n",
+		"b.tiny":          "print(missing);\n",
+		"sub/c.tiny":      "func f() { return 1; }\nfunc f() { return 2; }\n",
+		"sub/d_test.tiny": "assert_true(nope);\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b, c := filepath.Join(dir, "a.tiny"), filepath.Join(dir, "b.tiny"), filepath.Join(dir, "sub", "c.tiny")
+
+	var stdout, stderr bytes.Buffer
+	if code := runCheck([]string{a, "-"}, strings.NewReader("print(1);"), &stdout, &stderr); code != exitOK {
+		t.Errorf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := runCheck([]string{dir}, nil, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	expected := b + ":1:7: undefined: missing (undefined)\n" +
+		c + ":2:6: f already declared on line 1 (duplicate-function)\n"
+	if stderr.String() != expected {
+		t.Errorf("wrong human output.\nexpected:\n%s\ngot:\n%s", expected, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := runCheck([]string{"-format", "json", b, "-"}, strings.NewReader("let = 1;"), &stdout, &stderr); code != exitParseError {
+		t.Errorf("expected exit code %d, got %d", exitParseError, code)
+	}
+	var problems []checkProblem
+	if err := json.Unmarshal(stdout.Bytes(), &problems); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	if len(problems) != 3 || problems[0] != (checkProblem{File: b, Line: 1, Column: 7, Rule: "undefined", Message: "undefined: missing"}) ||
+		problems[1].File != "<stdin>" || problems[1].Rule != "syntax" {
+		t.Errorf("wrong JSON problems: %+v", problems)
+	}
+
+	stdout.Reset()
+	runCheck([]string{"-format", "github", "-"}, strings.NewReader("func f() {}\nfunc f() {}\nf(1, 2);"), &stdout, &stderr)
+	expected = "::error file=<stdin>,line=2,col=6,title=duplicate-function::f already declared on line 1\n" +
+		"::error file=<stdin>,line=3,col=1,title=arity::wrong number of arguments to f: got=2, want=0\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong GitHub output.\nexpected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	if code := runCheck([]string{"-format", "xml", a}, nil, &stdout, &stderr); code != exitUsage {
+		t.Errorf("expected exit code %d for an unknown format, got %d", exitUsage, code)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import "strings"
+
+// waiter is a goroutine blocked on a channel operation or on a task.
+// Waiters are only touched while holding the Runtime lock.
+type waiter struct {
+	value  Object
+	done   bool
+	err    *Error
+	wakeup chan struct{}
+}
+
+// channelElemTypes lists the object types a channel can be restricted to
+var channelElemTypes = map[ObjectType]bool{
+	INTEGER_OBJ:  true,
+	DECIMAL_OBJ:  true,
+	BOOLEAN_OBJ:  true,
+	STRING_OBJ:   true,
+	NULL_OBJ:     true,
+	FUNCTION_OBJ: true,
+	BUILTIN_OBJ:  true,
+	TASK_OBJ:     true,
+	CHANNEL_OBJ:  true,
+	ERR_OBJ:      true,
+	OK_OBJ:       true,
+	ARRAY_OBJ:    true,
+}
+
+func init() {
+	builtins["await"] = &Builtin{Name: "await", Fn: builtinAwait}
+	builtins["chan"] = &Builtin{Name: "chan", Fn: builtinChan}
+	builtins["send"] = &Builtin{Name: "send", Fn: builtinSend}
+	builtins["recv"] = &Builtin{Name: "recv", Fn: builtinRecv}
+	builtins["recv_any"] = &Builtin{Name: "recv_any", Fn: builtinRecvAny}
+}
+
+// evalSpawnExpression evaluates the call's function and arguments in the
+// current task, then applies the function on a new goroutine
+func evalSpawnExpression(se *SpawnExpression, env *Environment) Object {
+	function := Eval(se.Call.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+	args, named, abrupt := evalArguments(se.Call.Arguments, env)
+	if abrupt != nil {
+		return abrupt
+	}
+
+	switch function.(type) {
+	case *Function, *Builtin:
+	default:
+		return newErrorKind(TYPE_ERROR, "not a function: %T", function)
+	}
+
+	rt := env.rt
+	rt.mu.Lock()
+	rt.nextTaskID++
+	task := &Task{ID: rt.nextTaskID}
+	rt.running++
+	rt.mu.Unlock()
+
+	// The task gets a root frame of its own, so its calls are not mistaken
+	// for those of the spawning code
+	taskEnv := NewEnclosedEnvironment(env)
+	taskEnv.frame = &Frame{Caller: env.frame, Env: taskEnv, TaskID: task.ID}
+
+	go func() {
+		result := callFunction(se, se.Token, function, args, named, taskEnv)
+
+		rt.mu.Lock()
+		task.done = true
+		task.result = result
+		for _, w := range task.await {
+			rt.wake(w, result)
+		}
+		task.await = nil
+		rt.running--
+		rt.detectDeadlock()
+		rt.mu.Unlock()
+	}()
+
+	return task
+}
+
+// builtinAwait blocks until a task finishes and returns its result
+func builtinAwait(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to await: got=%d, want=1", len(args))
+	}
+	task, ok := args[0].(*Task)
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "argument to await must be TASK, got %s", args[0].Type())
+	}
+
+	rt := env.rt
+	rt.mu.Lock()
+	if task.done {
+		rt.mu.Unlock()
+		return task.result
+	}
+
+	w := &waiter{wakeup: make(chan struct{})}
+	task.await = append(task.await, w)
+	return rt.block(w)
+}
+
+// builtinChan creates a channel. It accepts an optional element type name
+// and an optional buffer capacity: chan(), chan("INTEGER"), chan(4) or
+// chan("STRING", 4).
+func builtinChan(env *Environment, args ...Object) Object {
+	if len(args) > 2 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to chan: got=%d, want at most 2", len(args))
+	}
+
+	ch := &Channel{}
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *String:
+			if i != 0 {
+				return newErrorKind(VALUE_ERROR, "chan element type must be the first argument")
+			}
+			elemType := ObjectType(strings.ToUpper(arg.Value))
+			if !channelElemTypes[elemType] {
+				return newErrorKind(VALUE_ERROR, "unknown channel element type: %s", arg.Value)
+			}
+			ch.ElemType = elemType
+		case *Integer:
+			if arg.Value < 0 {
+				return newErrorKind(VALUE_ERROR, "negative channel capacity: %d", arg.Value)
+			}
+			ch.Capacity = int(arg.Value)
+		default:
+			return newErrorKind(TYPE_ERROR, "argument to chan not supported, got %s", arg.Type())
+		}
+	}
+
+	rt := env.rt
+	rt.mu.Lock()
+	rt.nextChannelID++
+	ch.ID = rt.nextChannelID
+	rt.mu.Unlock()
+
+	return ch
+}
+
+// builtinSend sends a value on a channel, blocking until a receiver takes
+// it or there is room in the buffer
+func builtinSend(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to send: got=%d, want=2", len(args))
+	}
+	ch, ok := args[0].(*Channel)
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "first argument to send must be CHANNEL, got %s", args[0].Type())
+	}
+	value := args[1]
+	if ch.ElemType != "" && value.Type() != ch.ElemType {
+		return newErrorKind(TYPE_ERROR, "cannot send %s on %s", value.Type(), ch.Inspect())
+	}
+
+	rt := env.rt
+	rt.mu.Lock()
+
+	for len(ch.recvq) > 0 {
+		w := ch.recvq[0]
+		ch.recvq = ch.recvq[1:]
+		if w.done {
+			continue
+		}
+		rt.wake(w, value)
+		rt.mu.Unlock()
+		return NULL
+	}
+
+	if len(ch.buffer) < ch.Capacity {
+		ch.buffer = append(ch.buffer, value)
+		rt.mu.Unlock()
+		return NULL
+	}
+
+	w := &waiter{value: value, wakeup: make(chan struct{})}
+	ch.sendq = append(ch.sendq, w)
+	if res := rt.block(w); isError(res) {
+		return res
+	}
+	return NULL
+}
+
+// builtinRecv receives a value from a channel, blocking until one is sent
+func builtinRecv(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to recv: got=%d, want=1", len(args))
+	}
+	return recvAny(env.rt, "recv", args)
+}
+
+// builtinRecvAny receives from whichever of its channels has a value first.
+// When several are ready, the earliest argument wins.
+func builtinRecvAny(env *Environment, args ...Object) Object {
+	if len(args) == 0 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to recv_any: got=0, want at least 1")
+	}
+	return recvAny(env.rt, "recv_any", args)
+}
+
+// recvAny implements recv and recv_any
+func recvAny(rt *Runtime, name string, args []Object) Object {
+	channels := make([]*Channel, len(args))
+	for i, arg := range args {
+		ch, ok := arg.(*Channel)
+		if !ok {
+			return newErrorKind(TYPE_ERROR, "argument to %s must be CHANNEL, got %s", name, arg.Type())
+		}
+		channels[i] = ch
+	}
+
+	rt.mu.Lock()
+
+	for _, ch := range channels {
+		if value, ok := rt.tryRecv(ch); ok {
+			rt.mu.Unlock()
+			return value
+		}
+	}
+
+	w := &waiter{wakeup: make(chan struct{})}
+	for _, ch := range channels {
+		ch.recvq = append(ch.recvq, w)
+	}
+	return rt.block(w)
+}
+
+// tryRecv takes a value from a channel's buffer or from a blocked sender
+// without blocking. The caller must hold the Runtime lock.
+func (rt *Runtime) tryRecv(ch *Channel) (Object, bool) {
+	sender := rt.nextSender(ch)
+
+	if len(ch.buffer) > 0 {
+		value := ch.buffer[0]
+		ch.buffer = ch.buffer[1:]
+		if sender != nil {
+			ch.buffer = append(ch.buffer, sender.value)
+			rt.wake(sender, NULL)
+		}
+		return value, true
+	}
+
+	if sender != nil {
+		value := sender.value
+		rt.wake(sender, NULL)
+		return value, true
+	}
+
+	return nil, false
+}
+
+// nextSender pops the first sender still blocked on ch, if any. The caller
+// must hold the Runtime lock.
+func (rt *Runtime) nextSender(ch *Channel) *waiter {
+	for len(ch.sendq) > 0 {
+		w := ch.sendq[0]
+		ch.sendq = ch.sendq[1:]
+		if !w.done {
+			return w
+		}
+	}
+	return nil
+}
+
+// block parks the calling goroutine until w is woken. The caller must hold
+// the Runtime lock, which block releases.
+func (rt *Runtime) block(w *waiter) Object {
+	rt.running--
+	rt.blocked[w] = struct{}{}
+	rt.detectDeadlock()
+	rt.mu.Unlock()
+
+	<-w.wakeup
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.value
+}
+
+// wake resumes a blocked waiter with value. The caller must hold the
+// Runtime lock.
+func (rt *Runtime) wake(w *waiter, value Object) {
+	if w.done {
+		return
+	}
+	w.done = true
+	w.value = value
+	delete(rt.blocked, w)
+	rt.running++
+	close(w.wakeup)
+}
+
+// detectDeadlock fails every blocked waiter once no goroutine of the run can
+// make progress. Because all channel state is guarded by the Runtime lock,
+// this happens exactly when the program can no longer continue. The caller
+// must hold the Runtime lock.
+func (rt *Runtime) detectDeadlock() {
+	if rt.running > 0 || len(rt.blocked) == 0 {
+		return
+	}
+
+	for w := range rt.blocked {
+		w.err = newErrorKind(DEADLOCK_ERROR, "deadlock: all tasks are blocked")
+		rt.wake(w, nil)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// parseFile parses a TinyLang file, failing the test on any parse error
+func parseFile(t testing.TB, filename string) *Program {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filename, err)
+	}
+
+	p := NewParser(New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors in %s: %v", filename, p.Errors())
+	}
+	return program
+}
+
+func TestFrozenEnvironment(t *testing.T) {
+	prelude, err := NewPrelude(`let rate = 15; func bonus(salary) { return salary * rate / 100; }`)
+	if err != nil {
+		t.Fatalf("NewPrelude: %v", err)
+	}
+
+	if !prelude.Frozen() {
+		t.Fatalf("prelude is not frozen")
+	}
+
+	if res := prelude.Set("rate", &Integer{Value: 1}); !isError(res) {
+		t.Fatalf("expected error assigning to frozen environment, got %T (%+v)", res, res)
+	}
+
+	env := NewRunEnvironment(prelude)
+	program := NewParser(New(`let rate = 10; bonus(1000) + rate;`)).ParseProgram()
+	testIntegerObject(t, Eval(program, env), 160)
+
+	if val, _ := prelude.Get("rate"); val.(*Integer).Value != 15 {
+		t.Errorf("run leaked into prelude: rate=%s", val.Inspect())
+	}
+}
+
+func TestPreludeErrors(t *testing.T) {
+	if _, err := NewPrelude(`let = ;`); err == nil {
+		t.Errorf("expected parse error from NewPrelude")
+	}
+	if _, err := NewPrelude(`let x = missing;`); err == nil {
+		t.Errorf("expected runtime error from NewPrelude")
+	}
+}
+
+// TestConcurrentExamples runs every example many times in parallel against
+// a shared prelude. Run with -race to check the interpreter for data races.
+func TestConcurrentExamples(t *testing.T) {
+	files, err := filepath.Glob("examples/*.tiny")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example files found: %v", err)
+	}
+
+	prelude, err := NewPrelude(`func twice(x) { return x * 2; }`)
+	if err != nil {
+		t.Fatalf("NewPrelude: %v", err)
+	}
+
+	programs := make([]*Program, len(files))
+	expected := make([]string, len(files))
+	for i, file := range files {
+		programs[i] = parseFile(t, file)
+		expected[i] = Eval(programs[i], NewRunEnvironment(prelude)).Inspect()
+	}
+
+	const workers = 16
+	const iterations = 25
+
+	var wg sync.WaitGroup
+	errs := make(chan string, workers*iterations*len(files))
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				for j, program := range programs {
+					env := NewRunEnvironment(prelude)
+					if got := Eval(program, env).Inspect(); got != expected[j] {
+						errs <- files[j] + ": got " + got + ", want " + expected[j]
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for msg := range errs {
+		t.Error(msg)
+	}
+}
+
+// TestConcurrentSharedEnvironment exercises concurrent reads and writes on
+// one environment, which must be safe even though runs normally own theirs.
+func TestConcurrentSharedEnvironment(t *testing.T) {
+	env := NewEnvironment()
+	program := NewParser(New(`func add(a, b) { return a + b; } let x = add(1, 2); x;`)).ParseProgram()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				testIntegerObject(t, Eval(program, env), 3)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSpawnAndAwait(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"func square(x) { return x * x; } await(spawn square(7));", 49},
+		{`
+func fib(n) {
+	if (n <= 1) {
+		return n;
+	}
+	return fib(n - 1) + fib(n - 2);
+}
+let a = spawn fib(10);
+let b = spawn fib(12);
+await(a) + await(b);
+`, 199},
+		{"func one() { return 1; } let t = spawn one(); await(t) + await(t);", 2},
+		{"let t = spawn len(\"four\"); await(t);", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+func producer(ch, n) {
+	if (n == 0) {
+		return 0;
+	}
+	send(ch, n);
+	return producer(ch, n - 1);
+}
+func consumer(ch, n, total) {
+	if (n == 0) {
+		return total;
+	}
+	return consumer(ch, n - 1, total + recv(ch));
+}
+let ch = chan("INTEGER");
+spawn producer(ch, 10);
+consumer(ch, 10, 0);
+`, 55},
+		{`let ch = chan(2); send(ch, 1); send(ch, 2); recv(ch) * 10 + recv(ch);`, 12},
+		{`
+func worker(out, x) { send(out, x * 100); }
+let a = chan();
+let b = chan();
+spawn worker(b, 3);
+recv_any(a, b);
+`, 300},
+		{`let a = chan(1); let b = chan(1); send(a, 1); send(b, 2); recv_any(b, a);`, 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestConcurrencyErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`let ch = chan(); recv(ch);`, "deadlock: all tasks are blocked"},
+		{`let ch = chan(); send(ch, 1);`, "deadlock: all tasks are blocked"},
+		{`
+func waitFor(ch) { return recv(ch); }
+let a = chan();
+let t = spawn waitFor(a);
+await(t);
+`, "deadlock: all tasks are blocked"},
+		{`
+func ping(in, out) { send(out, recv(in)); }
+let a = chan();
+let b = chan();
+spawn ping(a, b);
+spawn ping(b, a);
+recv(chan());
+`, "deadlock: all tasks are blocked"},
+		{`let ch = chan("INTEGER", 1); send(ch, "one");`, "cannot send STRING on chan#1<INTEGER>"},
+		{`chan("WIDGET");`, "unknown channel element type: WIDGET"},
+		{`await(5);`, "argument to await must be TASK, got INTEGER"},
+		{`func fail() { return missing; } await(spawn fail());`, "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestSpawnParsing(t *testing.T) {
+	p := NewParser(New("spawn worker(1, 2);"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ExpressionStatement)
+	spawn, ok := stmt.Expression.(*SpawnExpression)
+	if !ok {
+		t.Fatalf("expression is not *SpawnExpression. got=%T", stmt.Expression)
+	}
+	if spawn.String() != "spawn worker(1, 2)" {
+		t.Errorf("spawn.String() wrong. got=%q", spawn.String())
+	}
+
+	p = NewParser(New("spawn 5;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parse error for spawn without a call")
+	}
+}
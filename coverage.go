@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// coverageFile holds the coverage of one source file
+type coverageFile struct {
+	name       string
+	source     string
+	statements []Statement // in source order
+	ifs        []*IfStatement
+}
+
+// Coverage records which statements ran and which way each if statement
+// went. Programs are registered with AddFile before they run.
+type Coverage struct {
+	BaseHooks
+
+	files []*coverageFile
+
+	mu       sync.Mutex
+	counts   map[Statement]int
+	branches map[*IfStatement]*[2]int // times the then and else branches were taken
+}
+
+// NewCoverage creates an empty coverage recorder
+func NewCoverage() *Coverage {
+	return &Coverage{counts: map[Statement]int{}, branches: map[*IfStatement]*[2]int{}}
+}
+
+// AddFile registers the statements of a parsed program, so that those
+// that never run are reported too
+func (c *Coverage) AddFile(name, source string, program *Program) {
+	file := &coverageFile{name: name, source: source}
+	var visit func(stmts []Statement)
+	visitBlock := func(block *BlockStatement) {
+		if block != nil {
+			visit(block.Statements)
+		}
+	}
+	visit = func(stmts []Statement) {
+		for _, stmt := range stmts {
+			file.statements = append(file.statements, stmt)
+			switch s := stmt.(type) {
+			case *FunctionStatement:
+				visitBlock(s.Body)
+			case *IfStatement:
+				file.ifs = append(file.ifs, s)
+				visitBlock(s.Consequence)
+				visitBlock(s.Alternative)
+			case *TryStatement:
+				visitBlock(s.Block)
+				visitBlock(s.Catch)
+				visitBlock(s.Finally)
+			case *BlockStatement:
+				visitBlock(s)
+			}
+		}
+	}
+	visit(program.Statements)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stmt := range file.statements {
+		c.counts[stmt] = 0
+	}
+	for _, ifs := range file.ifs {
+		c.branches[ifs] = &[2]int{}
+	}
+	c.files = append(c.files, file)
+}
+
+// OnStatement implements EvalHooks
+func (c *Coverage) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	c.mu.Lock()
+	if _, ok := c.counts[stmt]; ok {
+		c.counts[stmt]++
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// OnBranch implements BranchHook
+func (c *Coverage) OnBranch(stmt *IfStatement, taken bool, env *Environment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if branch, ok := c.branches[stmt]; ok {
+		if taken {
+			branch[0]++
+		} else {
+			branch[1]++
+		}
+	}
+}
+
+// CoverageSummary counts the covered statements and branches
+type CoverageSummary struct {
+	Statements, CoveredStatements int
+	Branches, CoveredBranches     int
+}
+
+// StatementPercent returns the share of statements that ran
+func (s CoverageSummary) StatementPercent() float64 {
+	return percent(s.CoveredStatements, s.Statements)
+}
+
+// BranchPercent returns the share of if branches that were taken
+func (s CoverageSummary) BranchPercent() float64 {
+	return percent(s.CoveredBranches, s.Branches)
+}
+
+// String formats the summary the way "run --cover" prints it
+func (s CoverageSummary) String() string {
+	return fmt.Sprintf("coverage: %.1f%% of statements (%d/%d), %.1f%% of branches (%d/%d)",
+		s.StatementPercent(), s.CoveredStatements, s.Statements,
+		s.BranchPercent(), s.CoveredBranches, s.Branches)
+}
+
+// percent returns part as a percentage of total; nothing to cover counts
+// as fully covered
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// Summary totals the coverage of every file
+func (c *Coverage) Summary() CoverageSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var s CoverageSummary
+	for _, file := range c.files {
+		for _, stmt := range file.statements {
+			s.Statements++
+			if c.counts[stmt] > 0 {
+				s.CoveredStatements++
+			}
+		}
+		for _, ifs := range file.ifs {
+			for _, n := range c.branches[ifs] {
+				s.Branches++
+				if n > 0 {
+					s.CoveredBranches++
+				}
+			}
+		}
+	}
+	return s
+}
+
+// lineCoverage is what is known about one source line
+type lineCoverage struct {
+	count   int // the most times any statement on the line ran
+	missed  bool
+	partial bool // an if on the line has a branch that was never taken
+}
+
+// lines maps line numbers of a file to their coverage
+func (c *Coverage) lines(file *coverageFile) map[int]*lineCoverage {
+	lines := map[int]*lineCoverage{}
+	for _, stmt := range file.statements {
+		line := statementToken(stmt).Line
+		lc, ok := lines[line]
+		if !ok {
+			lc = &lineCoverage{}
+			lines[line] = lc
+		}
+		n := c.counts[stmt]
+		if n > lc.count {
+			lc.count = n
+		}
+		if n == 0 {
+			lc.missed = true
+		}
+	}
+	for _, ifs := range file.ifs {
+		branch := c.branches[ifs]
+		if branch[0] == 0 || branch[1] == 0 {
+			lines[ifs.Token.Line].partial = true
+		}
+	}
+	return lines
+}
+
+// sortedLines returns the keys of lines in order
+func sortedLines(lines map[int]*lineCoverage) []int {
+	keys := make([]int, 0, len(lines))
+	for line := range lines {
+		keys = append(keys, line)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out strings.Builder
+	for _, file := range c.files {
+		out.WriteString("TN:\n")
+		fmt.Fprintf(&out, "SF:%s\n", file.name)
+
+		branchesFound, branchesHit := 0, 0
+		for i, ifs := range file.ifs {
+			executed := c.counts[ifs] > 0
+			for b, n := range c.branches[ifs] {
+				branchesFound++
+				taken := "-"
+				if executed {
+					taken = fmt.Sprint(n)
+				}
+				if n > 0 {
+					branchesHit++
+				}
+				fmt.Fprintf(&out, "BRDA:%d,%d,%d,%s\n", ifs.Token.Line, i, b, taken)
+			}
+		}
+		fmt.Fprintf(&out, "BRF:%d\nBRH:%d\n", branchesFound, branchesHit)
+
+		lines := c.lines(file)
+		linesHit := 0
+		for _, line := range sortedLines(lines) {
+			count := lines[line].count
+			if count > 0 {
+				linesHit++
+			}
+			fmt.Fprintf(&out, "DA:%d,%d\n", line, count)
+		}
+		fmt.Fprintf(&out, "LF:%d\nLH:%d\n", len(lines), linesHit)
+		out.WriteString("end_of_record\n")
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+const coverageHTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>TinyLang coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { font-family: monospace; line-height: 1.3; }
+.num, .count { display: inline-block; width: 4em; text-align: right; color: #888; margin-right: 1em; }
+.covered { background: #d7f5d7; }
+.uncovered { background: #f8d4d4; }
+.partial { background: #fbf0c4; }
+</style>
+</head>
+<body>
+`
+
+// WriteHTML writes the sources annotated with how often each line ran.
+// Lines whose statements all ran are green, lines with a statement that
+// never ran are red, and lines with an untaken if branch are yellow.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	summary := c.Summary()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out strings.Builder
+	out.WriteString(coverageHTMLHeader)
+	fmt.Fprintf(&out, "<p>%s</p>\n", html.EscapeString(summary.String()))
+	for _, file := range c.files {
+		fmt.Fprintf(&out, "<h2>%s</h2>\n<pre>\n", html.EscapeString(file.name))
+		lines := c.lines(file)
+		for i, text := range strings.Split(file.source, "\n") {
+			class, count := "line", ""
+			if lc, ok := lines[i+1]; ok {
+				count = fmt.Sprint(lc.count)
+				switch {
+				case lc.missed && lc.count == 0:
+					class += " uncovered"
+				case lc.missed || lc.partial:
+					class += " partial"
+				default:
+					class += " covered"
+				}
+			}
+			fmt.Fprintf(&out, "<span class=\"%s\"><span class=\"num\">%d</span><span class=\"count\">%s</span>%s</span>\n",
+				class, i+1, count, html.EscapeString(text))
+		}
+		out.WriteString("</pre>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
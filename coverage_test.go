@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const coverageTestProgram = `func classify(n) {
+    if (n < 0) {
+        return "negative";
+    } else {
+        if (n == 0) {
+            return "zero";
+        }
+    }
+    return "positive";
+}
+classify(5);
+classify(0);`
+
+func coverProgram(t *testing.T, input string) *Coverage {
+	t.Helper()
+	program := NewParser(New(input)).ParseProgram()
+	coverage := NewCoverage()
+	coverage.AddFile("prog.tiny", input, program)
+
+	env := NewEnvironment()
+	env.AddHooks(coverage)
+	if result := Eval(program, env); isError(result) {
+		t.Fatalf("program failed: %s", result.Inspect())
+	}
+	return coverage
+}
+
+func TestCoverageSummary(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected CoverageSummary
+	}{
+		{coverageTestProgram, CoverageSummary{Statements: 8, CoveredStatements: 7, Branches: 4, CoveredBranches: 3}},
+		{"let x = 1;\nx;", CoverageSummary{Statements: 2, CoveredStatements: 2}},
+		{"func f() {\n    return 1;\n}\ntry {\n    throw \"x\";\n    f();\n} catch (e) {\n    e;\n}",
+			CoverageSummary{Statements: 6, CoveredStatements: 4}},
+	}
+
+	for _, tt := range tests {
+		if got := coverProgram(t, tt.input).Summary(); got != tt.expected {
+			t.Errorf("wrong summary for %q.\nexpected=%+v\ngot=     %+v", tt.input, tt.expected, got)
+		}
+	}
+
+	summary := coverProgram(t, coverageTestProgram).Summary()
+	if summary.String() != "coverage: 87.5% of statements (7/8), 75.0% of branches (3/4)" {
+		t.Errorf("wrong summary text: %s", summary)
+	}
+}
+
+func TestCoverageLCOV(t *testing.T) {
+	var out bytes.Buffer
+	if err := coverProgram(t, coverageTestProgram).WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `TN:
+SF:prog.tiny
+BRDA:2,0,0,0
+BRDA:2,0,1,2
+BRDA:5,1,0,1
+BRDA:5,1,1,1
+BRF:4
+BRH:3
+DA:1,1
+DA:2,2
+DA:3,0
+DA:5,2
+DA:6,1
+DA:9,1
+DA:11,1
+DA:12,1
+LF:8
+LH:7
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("wrong LCOV.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// Branches of an if that never ran are marked as not executed
+	out.Reset()
+	coverProgram(t, "func f(n) {\n    if (n) {\n        n;\n    }\n}").WriteLCOV(&out)
+	if !strings.Contains(out.String(), "BRDA:2,0,0,-\nBRDA:2,0,1,-\n") {
+		t.Errorf("expected unexecuted branches, got:\n%s", out.String())
+	}
+}
+
+func TestCoverageHTML(t *testing.T) {
+	var out bytes.Buffer
+	if err := coverProgram(t, coverageTestProgram).WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+
+	for _, want := range []string{
+		`<span class="line covered"><span class="num">1</span><span class="count">1</span>func classify(n) {</span>`,
+		`<span class="line partial"><span class="num">2</span><span class="count">2</span>    if (n &lt; 0) {</span>`,
+		`<span class="line uncovered"><span class="num">3</span><span class="count">0</span>        return &#34;negative&#34;;</span>`,
+		`<span class="line"><span class="num">4</span><span class="count"></span>    } else {</span>`,
+		"87.5% of statements",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML is missing %s", want)
+		}
+	}
+}
+
+func TestRunCover(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prog.tiny")
+	if err := os.WriteFile(file, []byte(coverageTestProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lcov := filepath.Join(dir, "lcov.info")
+	page := filepath.Join(dir, "cover.html")
+
+	var stdout, stderr bytes.Buffer
+	if code := runRun([]string{"--coverprofile", lcov, "--coverhtml", page, file}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if stderr.String() != "coverage: 87.5% of statements (7/8), 75.0% of branches (3/4)\n" {
+		t.Errorf("wrong summary: %q", stderr.String())
+	}
+	for _, path := range []string{lcov, page} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("%s not written: %v", path, err)
+		}
+	}
+}
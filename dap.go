@@ -0,0 +1,536 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// dapThreadID is the only thread reported to clients: the main program
+const dapThreadID = 1
+
+// dapRequest is an incoming Debug Adapter Protocol request
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// dapCommand is work a request hands to the paused program goroutine,
+// which owns the interpreter state while it is stopped
+type dapCommand struct {
+	run  func(d *Debugger, p *Pause) (resume bool)
+	done chan struct{}
+}
+
+// dapHandle is what a variablesReference points to
+type dapHandle struct {
+	variables []Variable
+}
+
+// dapServer adapts the Debugger to the Debug Adapter Protocol. Requests
+// are read on one goroutine while the program runs on another.
+type dapServer struct {
+	conn *rpcConn
+
+	seqMu sync.Mutex
+	seq   int
+
+	debugger *Debugger
+	program  *Program
+	path     string
+	env      *Environment
+
+	launched   bool
+	configured bool
+	started    bool
+	finished   chan struct{}
+
+	// mu guards the pause state shared with the program goroutine
+	mu       sync.Mutex
+	pause    *Pause
+	stack    []StackEntry
+	handles  []dapHandle
+	commands chan dapCommand
+	abort    bool
+}
+
+// runDAP implements "tinylang dap", serving the Debug Adapter Protocol
+// over the given streams until the client disconnects
+func runDAP(stdin io.Reader, stdout, stderr io.Writer) int {
+	s := &dapServer{
+		conn:     newRPCConn(stdin, stdout),
+		commands: make(chan dapCommand),
+		finished: make(chan struct{}),
+	}
+	s.debugger = NewDebugger(s)
+
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(stderr, "dap: %v\n", err)
+			}
+			s.stop()
+			return 1
+		}
+
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			fmt.Fprintf(stderr, "dap: %v\n", err)
+			continue
+		}
+
+		if action, ok := dapResumeActions[req.Command]; ok {
+			// Answer before resuming, so the response cannot arrive after
+			// the next stopped event
+			if !s.isPaused() {
+				s.respond(req, false, "the program is not paused", nil)
+				continue
+			}
+			s.respond(req, true, "", map[string]bool{"allThreadsContinued": true})
+			s.inPause(func(d *Debugger, p *Pause) bool {
+				action(d, p)
+				return true
+			})
+			continue
+		}
+
+		result, err := s.handle(req)
+		if err != nil {
+			s.respond(req, false, err.Error(), nil)
+		} else {
+			s.respond(req, true, "", result)
+		}
+
+		switch req.Command {
+		case "initialize":
+			s.event("initialized", nil)
+		case "launch", "configurationDone":
+			s.start()
+		case "disconnect", "terminate":
+			s.stop()
+			return 0
+		}
+	}
+}
+
+// dapResumeActions are the requests that resume a paused program
+var dapResumeActions = map[string]func(d *Debugger, p *Pause){
+	"continue": func(d *Debugger, p *Pause) { d.Continue() },
+	"next":     func(d *Debugger, p *Pause) { d.StepOver(p) },
+	"stepIn":   func(d *Debugger, p *Pause) { d.StepIn() },
+	"stepOut":  func(d *Debugger, p *Pause) { d.StepOut(p) },
+}
+
+// isPaused reports whether the program is stopped
+func (s *dapServer) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pause != nil
+}
+
+// send writes a message with the next sequence number
+func (s *dapServer) send(msg map[string]interface{}) {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+	s.seq++
+	msg["seq"] = s.seq
+	s.conn.write(msg)
+}
+
+// respond answers a request
+func (s *dapServer) respond(req dapRequest, success bool, message string, body interface{}) {
+	msg := map[string]interface{}{
+		"type":        "response",
+		"request_seq": req.Seq,
+		"command":     req.Command,
+		"success":     success,
+	}
+	if message != "" {
+		msg["message"] = message
+	}
+	if body != nil {
+		msg["body"] = body
+	}
+	s.send(msg)
+}
+
+// event sends a DAP event
+func (s *dapServer) event(name string, body interface{}) {
+	msg := map[string]interface{}{"type": "event", "event": name}
+	if body != nil {
+		msg["body"] = body
+	}
+	s.send(msg)
+}
+
+// Write sends program output to the client as output events
+func (s *dapServer) Write(p []byte) (int, error) {
+	s.event("output", map[string]string{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
+
+// handle performs one request and returns its response body
+func (s *dapServer) handle(req dapRequest) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+
+	case "launch":
+		return nil, s.launch(req.Arguments)
+
+	case "configurationDone":
+		s.configured = true
+		return nil, nil
+
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+
+	case "setExceptionBreakpoints":
+		return map[string]interface{}{"breakpoints": []interface{}{}}, nil
+
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThreadID, "name": "main"}},
+		}, nil
+
+	case "stackTrace":
+		return s.stackTrace()
+
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args.FrameID)
+
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args.Expression, args.FrameID)
+
+	case "disconnect", "terminate":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+// launch loads the program named by the "program" argument
+func (s *dapServer) launch(raw json.RawMessage) error {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	if s.launched {
+		return fmt.Errorf("a program is already launched")
+	}
+
+	content, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	p := NewParser(New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return fmt.Errorf("parse error: %s", p.Errors()[0])
+	}
+
+	s.program = program
+	s.path, _ = filepath.Abs(args.Program)
+	s.debugger.entry = args.StopOnEntry
+	s.launched = true
+	return nil
+}
+
+// start runs the program once it is launched and configured
+func (s *dapServer) start() {
+	if !s.launched || !s.configured || s.started {
+		return
+	}
+	s.started = true
+
+	s.env = NewEnvironment()
+	s.env.rt.SetOutput(s)
+	s.debugger.Attach(s.env)
+
+	go func() {
+		defer close(s.finished)
+		result := Eval(s.program, s.env)
+
+		exitCode := 0
+		if err, ok := result.(*Error); ok && !isDebuggerQuit(result) {
+			exitCode = 1
+			message := "Runtime Error: " + err.Inspect()
+			if err.Line > 0 {
+				message += fmt.Sprintf(" (line %d)", err.Line)
+			}
+			s.event("output", map[string]string{"category": "stderr", "output": message + "\n"})
+		}
+		s.event("exited", map[string]int{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// stop ends a running program and waits for it to finish
+func (s *dapServer) stop() {
+	if !s.started {
+		return
+	}
+	s.debugger.Terminate()
+
+	s.mu.Lock()
+	paused := s.pause != nil
+	s.abort = true
+	s.mu.Unlock()
+	if paused {
+		s.inPause(func(d *Debugger, p *Pause) bool { return true })
+	}
+	<-s.finished
+}
+
+// paused implements debugFrontend: it reports the stop to the client and
+// then runs the commands requests send until one resumes the program
+func (s *dapServer) paused(d *Debugger, p *Pause) Object {
+	s.mu.Lock()
+	if s.abort {
+		s.mu.Unlock()
+		return newDebuggerQuit()
+	}
+	s.pause, s.stack, s.handles = p, d.Stack(p), nil
+	s.mu.Unlock()
+
+	body := map[string]interface{}{
+		"reason":            p.Reason,
+		"threadId":          dapThreadID,
+		"allThreadsStopped": true,
+	}
+	if p.Breakpoint != nil {
+		body["hitBreakpointIds"] = []int{p.Breakpoint.ID}
+	}
+	if p.ConditionError != nil {
+		body["text"] = p.ConditionError.Inspect()
+	}
+	s.event("stopped", body)
+
+	for cmd := range s.commands {
+		resume := cmd.run(d, p)
+		if resume {
+			s.mu.Lock()
+			s.pause, s.stack, s.handles = nil, nil, nil
+			abort := s.abort
+			s.mu.Unlock()
+			close(cmd.done)
+			if abort {
+				return newDebuggerQuit()
+			}
+			return nil
+		}
+		close(cmd.done)
+	}
+	return nil
+}
+
+// inPause runs fn on the paused program goroutine and waits for it
+func (s *dapServer) inPause(fn func(d *Debugger, p *Pause) bool) error {
+	if !s.isPaused() {
+		return fmt.Errorf("the program is not paused")
+	}
+
+	cmd := dapCommand{run: fn, done: make(chan struct{})}
+	s.commands <- cmd
+	<-cmd.done
+	return nil
+}
+
+// setBreakpoints replaces the program's breakpoints
+func (s *dapServer) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	s.debugger.ClearBreakpoints()
+	result := []map[string]interface{}{}
+	for _, b := range args.Breakpoints {
+		bp, err := s.debugger.SetBreakpoint(b.Line, b.Condition)
+		if err != nil {
+			result = append(result, map[string]interface{}{"verified": false, "line": b.Line, "message": err.Error()})
+			continue
+		}
+		result = append(result, map[string]interface{}{"id": bp.ID, "verified": true, "line": bp.Line})
+	}
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// stackTrace reports the paused call stack; frame IDs are stack indexes
+// starting at 1
+func (s *dapServer) stackTrace() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pause == nil {
+		return nil, fmt.Errorf("the program is not paused")
+	}
+
+	frames := []map[string]interface{}{}
+	for i, entry := range s.stack {
+		frames = append(frames, map[string]interface{}{
+			"id":     i + 1,
+			"name":   entry.Name,
+			"line":   entry.Line,
+			"column": 1,
+			"source": map[string]string{"name": filepath.Base(s.path), "path": s.path},
+		})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// stackEntry returns the paused stack entry for a frame ID
+func (s *dapServer) stackEntry(frameID int) (StackEntry, error) {
+	if s.pause == nil {
+		return StackEntry{}, fmt.Errorf("the program is not paused")
+	}
+	if frameID < 1 || frameID > len(s.stack) {
+		return StackEntry{}, fmt.Errorf("unknown frame %d", frameID)
+	}
+	return s.stack[frameID-1], nil
+}
+
+// scopes maps the environment chain of a frame to DAP scopes
+func (s *dapServer) scopes(frameID int) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, err := s.stackEntry(frameID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for i, scope := range Scopes(entry.Env) {
+		s.handles = append(s.handles, dapHandle{variables: scope.Variables})
+		hint := "locals"
+		if i > 0 {
+			hint = "globals"
+		}
+		result = append(result, map[string]interface{}{
+			"name":               scope.Name,
+			"presentationHint":   hint,
+			"variablesReference": len(s.handles),
+			"expensive":          false,
+		})
+	}
+	return map[string]interface{}{"scopes": result}, nil
+}
+
+// variables lists a scope or the elements of a structured value
+func (s *dapServer) variables(ref int) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pause == nil {
+		return nil, fmt.Errorf("the program is not paused")
+	}
+	if ref < 1 || ref > len(s.handles) {
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+
+	result := []map[string]interface{}{}
+	for _, v := range s.handles[ref-1].variables {
+		result = append(result, map[string]interface{}{
+			"name":               v.Name,
+			"value":              debugInspect(v.Value),
+			"type":               string(v.Value.Type()),
+			"variablesReference": s.childHandle(v.Value),
+		})
+	}
+	return map[string]interface{}{"variables": result}, nil
+}
+
+// childHandle allocates a reference for values with parts the client can
+// expand, returning 0 for plain values
+func (s *dapServer) childHandle(obj Object) int {
+	var children []Variable
+	switch obj := obj.(type) {
+	case *Array:
+		for i, el := range obj.Elements {
+			children = append(children, Variable{Name: fmt.Sprintf("[%d]", i), Value: el})
+		}
+	case *Ok:
+		children = []Variable{{Name: "value", Value: obj.Value}}
+	case *Err:
+		children = []Variable{
+			{Name: "message", Value: &String{Value: obj.Error.Message}},
+			{Name: "kind", Value: &String{Value: obj.Error.Kind}},
+		}
+	default:
+		return 0
+	}
+	s.handles = append(s.handles, dapHandle{variables: children})
+	return len(s.handles)
+}
+
+// evaluate runs an expression in a paused frame
+func (s *dapServer) evaluate(expression string, frameID int) (interface{}, error) {
+	if frameID == 0 {
+		frameID = 1
+	}
+	s.mu.Lock()
+	entry, err := s.stackEntry(frameID)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var result Object
+	err = s.inPause(func(d *Debugger, p *Pause) bool {
+		result = d.Evaluate(&Pause{Line: p.Line, Env: entry.Env, Frame: p.Frame}, expression)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := result.(*Error); ok {
+		return nil, fmt.Errorf("%s", e.Inspect())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]interface{}{
+		"result":             debugInspect(result),
+		"type":               string(result.Type()),
+		"variablesReference": s.childHandle(result),
+	}, nil
+}
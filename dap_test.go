@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dapMessage is a response or event sent by the adapter
+type dapMessage struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Command    string          `json:"command"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// dapTestClient drives an in-process debug adapter over pipes
+type dapTestClient struct {
+	t        *testing.T
+	conn     *rpcConn
+	seq      int
+	done     chan int
+	messages chan []byte
+
+	// events holds events received but not yet awaited
+	events []dapMessage
+	output strings.Builder
+}
+
+func newDAPTestClient(t *testing.T) *dapTestClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &dapTestClient{
+		t:        t,
+		conn:     newRPCConn(clientIn, clientOut),
+		done:     make(chan int, 1),
+		messages: make(chan []byte, 100),
+	}
+	go func() {
+		c.done <- runDAP(serverIn, serverOut, io.Discard)
+		serverOut.Close()
+	}()
+	// The adapter writes events at any time, so read them as they come
+	go func() {
+		defer close(c.messages)
+		for {
+			body, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.messages <- body
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+// request sends a request and returns its response, queueing any events
+// that arrive first
+func (c *dapTestClient) request(command string, args interface{}, body interface{}) dapMessage {
+	c.t.Helper()
+	c.seq++
+	msg := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		msg["arguments"] = args
+	}
+	if err := c.conn.write(msg); err != nil {
+		c.t.Fatalf("sending %s: %v", command, err)
+	}
+
+	for {
+		resp := c.read()
+		if resp.Type == "event" {
+			c.events = append(c.events, resp)
+			continue
+		}
+		if resp.RequestSeq != c.seq || resp.Command != command {
+			c.t.Fatalf("%s: unexpected response %+v", command, resp)
+		}
+		if resp.Success && body != nil {
+			if err := json.Unmarshal(resp.Body, body); err != nil {
+				c.t.Fatalf("%s: decoding body %s: %v", command, resp.Body, err)
+			}
+		}
+		return resp
+	}
+}
+
+// waitEvent returns the next event with the given name, skipping others
+func (c *dapTestClient) waitEvent(name string, body interface{}) {
+	c.t.Helper()
+	for {
+		var msg dapMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.read()
+		}
+		if msg.Type != "event" {
+			c.t.Fatalf("waiting for %s: unexpected %+v", name, msg)
+		}
+		if msg.Event != name {
+			continue
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("decoding %s event: %v", name, err)
+			}
+		}
+		return
+	}
+}
+
+// read returns the next message from the adapter, recording output
+func (c *dapTestClient) read() dapMessage {
+	c.t.Helper()
+	body, ok := <-c.messages
+	if !ok {
+		c.t.Fatalf("adapter closed the connection")
+	}
+	var msg dapMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("decoding %s: %v", body, err)
+	}
+	if msg.Event == "output" {
+		var output struct {
+			Output string `json:"output"`
+		}
+		json.Unmarshal(msg.Body, &output)
+		c.output.WriteString(output.Output)
+	}
+	return msg
+}
+
+type dapStopped struct {
+	Reason           string `json:"reason"`
+	ThreadID         int    `json:"threadId"`
+	HitBreakpointIDs []int  `json:"hitBreakpointIds"`
+}
+
+type dapStackTrace struct {
+	StackFrames []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Line int    `json:"line"`
+	} `json:"stackFrames"`
+}
+
+// stoppedAt waits for the next stop and returns its reason and the
+// innermost frame as "name:line"
+func (c *dapTestClient) stoppedAt() (string, string) {
+	c.t.Helper()
+	var stopped dapStopped
+	c.waitEvent("stopped", &stopped)
+	var trace dapStackTrace
+	c.request("stackTrace", map[string]int{"threadId": dapThreadID}, &trace)
+	top := trace.StackFrames[0]
+	return stopped.Reason, top.Name + ":" + itoa(top.Line)
+}
+
+func itoa(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
+
+const dapTestProgram = `func add(a, b) {
+    let sum = a + b;
+    return sum;
+}
+func pair(...items) { return items; }
+let values = pair(1, 2);
+print("start");
+let result = add(values[0], values[1]);
+print(result);`
+
+func TestDAPSession(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prog.tiny")
+	if err := os.WriteFile(file, []byte(dapTestProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPTestClient(t)
+
+	var capabilities map[string]bool
+	c.request("initialize", map[string]string{"adapterID": "tinylang"}, &capabilities)
+	if !capabilities["supportsConfigurationDoneRequest"] || !capabilities["supportsConditionalBreakpoints"] {
+		t.Errorf("wrong capabilities: %v", capabilities)
+	}
+	c.waitEvent("initialized", nil)
+
+	if resp := c.request("launch", map[string]interface{}{"program": file, "stopOnEntry": true}, nil); !resp.Success {
+		t.Fatalf("launch failed: %s", resp.Message)
+	}
+
+	var bps struct {
+		Breakpoints []struct {
+			ID       int  `json:"id"`
+			Verified bool `json:"verified"`
+		} `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": file},
+		"breakpoints": []map[string]interface{}{{"line": 3, "condition": "sum > 2"}, {"line": 4, "condition": "a +"}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified || bps.Breakpoints[1].Verified {
+		t.Errorf("wrong breakpoints: %+v", bps)
+	}
+	c.request("configurationDone", nil, nil)
+
+	if reason, at := c.stoppedAt(); reason != "entry" || at != "<main>:1" {
+		t.Errorf("first stop %s at %s", reason, at)
+	}
+
+	var threads struct {
+		Threads []struct {
+			ID int `json:"id"`
+		} `json:"threads"`
+	}
+	c.request("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != dapThreadID {
+		t.Errorf("wrong threads: %+v", threads)
+	}
+
+	// Stepping over the definitions and into the call
+	c.request("next", map[string]int{"threadId": dapThreadID}, nil)
+	if reason, at := c.stoppedAt(); reason != "step" || at != "<main>:5" {
+		t.Errorf("after next: %s at %s", reason, at)
+	}
+	for i := 0; i < 3; i++ {
+		c.request("next", map[string]int{"threadId": dapThreadID}, nil)
+		c.stoppedAt()
+	}
+	if c.output.String() != "start\n" {
+		t.Errorf("wrong program output: %q", c.output.String())
+	}
+	c.request("stepIn", map[string]int{"threadId": dapThreadID}, nil)
+	if reason, at := c.stoppedAt(); reason != "step" || at != "add:2" {
+		t.Errorf("after stepIn: %s at %s", reason, at)
+	}
+
+	// Continuing hits the conditional breakpoint
+	c.request("continue", map[string]int{"threadId": dapThreadID}, nil)
+	var stopped dapStopped
+	c.waitEvent("stopped", &stopped)
+	if stopped.Reason != "breakpoint" || len(stopped.HitBreakpointIDs) != 1 || stopped.HitBreakpointIDs[0] != bps.Breakpoints[0].ID {
+		t.Errorf("wrong breakpoint stop: %+v", stopped)
+	}
+
+	var trace dapStackTrace
+	c.request("stackTrace", map[string]int{"threadId": dapThreadID}, &trace)
+	var names []string
+	for _, f := range trace.StackFrames {
+		names = append(names, f.Name+":"+itoa(f.Line))
+	}
+	if strings.Join(names, " ") != "add:3 <main>:8" {
+		t.Errorf("wrong stack: %v", names)
+	}
+
+	// Scopes and variables of the innermost frame
+	var scopes struct {
+		Scopes []struct {
+			Name               string `json:"name"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"scopes"`
+	}
+	c.request("scopes", map[string]int{"frameId": trace.StackFrames[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "locals of add" || scopes.Scopes[1].Name != "global" {
+		t.Fatalf("wrong scopes: %+v", scopes)
+	}
+
+	type dapVariables struct {
+		Variables []struct {
+			Name               string `json:"name"`
+			Value              string `json:"value"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"variables"`
+	}
+	var locals dapVariables
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference}, &locals)
+	var got []string
+	for _, v := range locals.Variables {
+		got = append(got, v.Name+"="+v.Value)
+	}
+	if strings.Join(got, " ") != "a=1 b=2 sum=3" {
+		t.Errorf("wrong locals: %v", got)
+	}
+
+	var globals dapVariables
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[1].VariablesReference}, &globals)
+	var valuesRef int
+	for _, v := range globals.Variables {
+		if v.Name == "values" {
+			valuesRef = v.VariablesReference
+		}
+	}
+	var elements dapVariables
+	if valuesRef == 0 {
+		t.Fatalf("values is not expandable: %+v", globals)
+	}
+	c.request("variables", map[string]int{"variablesReference": valuesRef}, &elements)
+	if len(elements.Variables) != 2 || elements.Variables[1].Name != "[1]" || elements.Variables[1].Value != "2" {
+		t.Errorf("wrong array elements: %+v", elements)
+	}
+
+	// Evaluating in the paused frame and in its caller
+	var eval struct {
+		Result string `json:"result"`
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "sum * 10", "frameId": 1}, &eval)
+	if eval.Result != "30" {
+		t.Errorf("evaluate gave %q, want 30", eval.Result)
+	}
+	if resp := c.request("evaluate", map[string]interface{}{"expression": "sum", "frameId": 2}, nil); resp.Success {
+		t.Errorf("expected sum to be undefined in the caller frame")
+	}
+
+	c.request("stepOut", map[string]int{"threadId": dapThreadID}, nil)
+	if reason, at := c.stoppedAt(); reason != "step" || at != "<main>:9" {
+		t.Errorf("after stepOut: %s at %s", reason, at)
+	}
+
+	c.request("continue", map[string]int{"threadId": dapThreadID}, nil)
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	c.waitEvent("exited", &exited)
+	c.waitEvent("terminated", nil)
+	if exited.ExitCode != 0 || c.output.String() != "start\n3\n" {
+		t.Errorf("wrong exit: code %d, output %q", exited.ExitCode, c.output.String())
+	}
+
+	if resp := c.request("next", map[string]int{"threadId": dapThreadID}, nil); resp.Success {
+		t.Errorf("expected next to fail once the program has ended")
+	}
+	c.request("disconnect", nil, nil)
+	if code := <-c.done; code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+}
+
+func TestDAPDisconnectWhilePaused(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "loop.tiny")
+	if err := os.WriteFile(file, []byte("func loop(i) {\n    return loop(i + 1);\n}\nloop(0);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPTestClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", map[string]interface{}{"program": file}, nil)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": file},
+		"breakpoints": []map[string]interface{}{{"line": 2, "condition": "i == 100"}},
+	}, nil)
+	c.request("configurationDone", nil, nil)
+	c.waitEvent("stopped", nil)
+
+	var eval struct {
+		Result string `json:"result"`
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "i"}, &eval)
+	if eval.Result != "100" {
+		t.Errorf("evaluate gave %q, want 100", eval.Result)
+	}
+
+	c.request("disconnect", nil, nil)
+	if code := <-c.done; code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+}
+
+func TestDAPLaunchErrors(t *testing.T) {
+	c := newDAPTestClient(t)
+	c.request("initialize", nil, nil)
+	if resp := c.request("launch", map[string]string{"program": "/no/such/file.tiny"}, nil); resp.Success {
+		t.Errorf("expected launch of a missing file to fail")
+	}
+	if resp := c.request("stackTrace", nil, nil); resp.Success {
+		t.Errorf("expected stackTrace to fail without a paused program")
+	}
+	if resp := c.request("bogus", nil, nil); resp.Success || !strings.Contains(resp.Message, "unsupported") {
+		t.Errorf("wrong response to unknown request: %+v", resp)
+	}
+	c.request("disconnect", nil, nil)
+	if code := <-c.done; code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const debugHelp = `Commands:
+  break LINE [if EXPR]  set a breakpoint, optionally conditional (b)
+  delete ID             remove a breakpoint (d)
+  breakpoints           list breakpoints (info)
+  continue              run to the next breakpoint (c)
+  step                  step to the next statement, entering calls (s)
+  next                  step over calls (n)
+  out                   run until the current function returns (o, finish)
+  print NAME            show a variable from the current scope chain (p)
+  locals                show every scope of the current frame (v)
+  eval EXPR             evaluate code in the current frame (e)
+  backtrace             show the call stack (bt)
+  list                  show the source around the current line (l)
+  quit                  stop the program (q)
+An empty line repeats the previous step command.`
+
+// consoleDebugger is the terminal frontend of "tinylang debug"
+type consoleDebugger struct {
+	in    *bufio.Scanner
+	out   io.Writer
+	lines []string
+	last  string
+}
+
+// runDebug implements "tinylang debug file.tiny". It returns the process
+// exit code.
+func runDebug(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "Usage: tinylang debug <file.tiny>")
+		return exitUsage
+	}
+
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "debug: %v\n", err)
+		return exitUsage
+	}
+
+	p := NewParser(New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintln(stderr, "Parse errors:")
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "  - %s\n", msg)
+		}
+		return exitParseError
+	}
+
+	console := &consoleDebugger{
+		in:    bufio.NewScanner(stdin),
+		out:   stdout,
+		lines: strings.Split(string(content), "\n"),
+	}
+	fmt.Fprintln(stdout, `TinyLang debugger. Type "help" for commands.`)
+
+	env := NewEnvironment()
+	NewDebugger(console).Attach(env)
+	result := Eval(program, env)
+
+	if isDebuggerQuit(result) {
+		fmt.Fprintln(stdout, "Program terminated.")
+		return exitOK
+	}
+	if err, ok := result.(*Error); ok {
+		if err.Line > 0 {
+			fmt.Fprintf(stdout, "Runtime Error: %s (line %d)\n", err.Inspect(), err.Line)
+		} else {
+			fmt.Fprintf(stdout, "Runtime Error: %s\n", err.Inspect())
+		}
+		return exitRuntimeError
+	}
+	fmt.Fprintf(stdout, "Program finished: %s\n", result.Inspect())
+	return exitOK
+}
+
+// paused shows where the program stopped and runs the command loop until
+// a command resumes it
+func (c *consoleDebugger) paused(d *Debugger, p *Pause) Object {
+	switch {
+	case p.ConditionError != nil:
+		fmt.Fprintf(c.out, "Breakpoint %d condition failed: %s\n", p.Breakpoint.ID, p.ConditionError.Inspect())
+	case p.Breakpoint != nil:
+		fmt.Fprintf(c.out, "Breakpoint %d, ", p.Breakpoint.ID)
+	}
+	fmt.Fprintf(c.out, "line %d: %s\n", p.Line, c.source(p.Line))
+
+	for {
+		fmt.Fprint(c.out, "(tdb) ")
+		if !c.in.Scan() {
+			// Input ended: let the program finish on its own
+			fmt.Fprintln(c.out)
+			d.Detach()
+			return nil
+		}
+
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch command {
+		case "":
+		case "c", "continue":
+			d.Continue()
+			return nil
+		case "s", "step":
+			c.last = command
+			d.StepIn()
+			return nil
+		case "n", "next":
+			c.last = command
+			d.StepOver(p)
+			return nil
+		case "o", "out", "finish":
+			c.last = command
+			d.StepOut(p)
+			return nil
+		case "q", "quit":
+			return newDebuggerQuit()
+		case "b", "break":
+			c.setBreakpoint(d, arg)
+		case "d", "delete":
+			id, err := strconv.Atoi(arg)
+			if err != nil || !d.ClearBreakpoint(id) {
+				fmt.Fprintf(c.out, "No breakpoint %q\n", arg)
+			} else {
+				fmt.Fprintf(c.out, "Deleted breakpoint %d\n", id)
+			}
+		case "info", "breakpoints":
+			c.listBreakpoints(d)
+		case "p", "print":
+			c.printVariable(p, arg)
+		case "v", "locals":
+			c.printScopes(p)
+		case "e", "eval":
+			fmt.Fprintln(c.out, d.Evaluate(p, arg).Inspect())
+		case "bt", "backtrace":
+			for i, entry := range d.Stack(p) {
+				fmt.Fprintf(c.out, "#%d %s at line %d\n", i, entry.Name, entry.Line)
+			}
+		case "l", "list":
+			c.list(p.Line)
+		case "h", "help":
+			fmt.Fprintln(c.out, debugHelp)
+		default:
+			fmt.Fprintf(c.out, "Unknown command %q. Type \"help\" for commands.\n", command)
+		}
+	}
+}
+
+// setBreakpoint handles "break LINE [if EXPR]"
+func (c *consoleDebugger) setBreakpoint(d *Debugger, arg string) {
+	lineText, condition, _ := strings.Cut(arg, " ")
+	condition = strings.TrimSpace(condition)
+	if condition != "" {
+		var ok bool
+		condition, ok = strings.CutPrefix(condition, "if ")
+		if !ok {
+			fmt.Fprintln(c.out, "Usage: break LINE [if EXPR]")
+			return
+		}
+	}
+
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		fmt.Fprintln(c.out, "Usage: break LINE [if EXPR]")
+		return
+	}
+	bp, err := d.SetBreakpoint(line, condition)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	if bp.Condition != "" {
+		fmt.Fprintf(c.out, "Breakpoint %d at line %d if %s\n", bp.ID, bp.Line, bp.Condition)
+	} else {
+		fmt.Fprintf(c.out, "Breakpoint %d at line %d\n", bp.ID, bp.Line)
+	}
+}
+
+// listBreakpoints prints every breakpoint
+func (c *consoleDebugger) listBreakpoints(d *Debugger) {
+	breakpoints := d.Breakpoints()
+	if len(breakpoints) == 0 {
+		fmt.Fprintln(c.out, "No breakpoints.")
+		return
+	}
+	for _, bp := range breakpoints {
+		fmt.Fprintf(c.out, "%d: line %d", bp.ID, bp.Line)
+		if bp.Condition != "" {
+			fmt.Fprintf(c.out, " if %s", bp.Condition)
+		}
+		fmt.Fprintf(c.out, " (hit %d times)\n", bp.Hits)
+	}
+}
+
+// printVariable looks a name up through the environment chain
+func (c *consoleDebugger) printVariable(p *Pause, name string) {
+	for _, scope := range Scopes(p.Env) {
+		for _, v := range scope.Variables {
+			if v.Name == name {
+				fmt.Fprintf(c.out, "%s = %s\n", name, debugInspect(v.Value))
+				return
+			}
+		}
+	}
+	fmt.Fprintf(c.out, "No variable %q in scope\n", name)
+}
+
+// printScopes lists every scope visible from the paused statement
+func (c *consoleDebugger) printScopes(p *Pause) {
+	for _, scope := range Scopes(p.Env) {
+		fmt.Fprintf(c.out, "%s:\n", scope.Name)
+		for _, v := range scope.Variables {
+			fmt.Fprintf(c.out, "  %s = %s\n", v.Name, debugInspect(v.Value))
+		}
+	}
+}
+
+// debugInspect renders a value on one line, showing functions by their
+// signature rather than their body
+func debugInspect(obj Object) string {
+	if fn, ok := obj.(*Function); ok {
+		return "func " + fn.Name + "(" + formatParameters(fn.Parameters, fn.Defaults, fn.Rest) + ")"
+	}
+	return obj.Inspect()
+}
+
+// list prints the source lines around line, marking the current one
+func (c *consoleDebugger) list(line int) {
+	for n := line - 2; n <= line+2; n++ {
+		if n < 1 || n > len(c.lines) {
+			continue
+		}
+		marker := "  "
+		if n == line {
+			marker = "=>"
+		}
+		fmt.Fprintf(c.out, "%s %3d  %s\n", marker, n, c.lines[n-1])
+	}
+}
+
+// source returns the trimmed text of a source line
+func (c *consoleDebugger) source(line int) string {
+	if line < 1 || line > len(c.lines) {
+		return ""
+	}
+	return strings.TrimSpace(c.lines[line-1])
+}
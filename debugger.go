@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// newDebuggerQuit returns the error passed back through the evaluator
+// when the user ends the program from the debugger. Each termination gets
+// its own, as the evaluator positions and marks the errors it returns.
+func newDebuggerQuit() *Error {
+	return &Error{Message: "program terminated by debugger", Kind: RUNTIME_ERROR, debuggerQuit: true}
+}
+
+// isDebuggerQuit reports whether a program's result is the error of a
+// termination from the debugger
+func isDebuggerQuit(obj Object) bool {
+	err, ok := obj.(*Error)
+	return ok && err.debuggerQuit
+}
+
+// stepMode says when the debugger should next pause on its own
+type stepMode int
+
+const (
+	runToBreakpoint stepMode = iota
+	stepIn                   // at the next statement
+	stepOver                 // at the next statement in the same or a calling frame
+	stepOut                  // at the next statement in a calling frame
+)
+
+// Breakpoint pauses the program when a statement on Line is about to run
+// and, if set, Condition evaluates to a truthy value in its scope
+type Breakpoint struct {
+	ID        int
+	Line      int
+	Condition string
+	Hits      int
+
+	condition Expression
+}
+
+// Pause describes where and why the program stopped
+type Pause struct {
+	Reason     string // "entry", "step" or "breakpoint"
+	Line       int
+	Env        *Environment
+	Frame      *Frame // nil when stopped in top-level code
+	Breakpoint *Breakpoint
+
+	// ConditionError is set when a breakpoint condition failed to evaluate
+	ConditionError *Error
+}
+
+// StackEntry is one level of the call stack of a paused program
+type StackEntry struct {
+	Name string
+	Line int
+	Env  *Environment
+}
+
+// debugFrontend is told when the program pauses. paused blocks until the
+// user resumes, having called one of the Debugger's resume methods, and
+// returns a non-nil object to abort the program instead.
+type debugFrontend interface {
+	paused(d *Debugger, p *Pause) Object
+}
+
+// Debugger decides when a running program pauses. It is installed as the
+// run's hooks and consulted before every statement of the main program;
+// spawned tasks run freely.
+type Debugger struct {
+	BaseHooks
+
+	mu               sync.Mutex
+	breakpoints      []*Breakpoint
+	nextBreakpointID int
+
+	frontend  debugFrontend
+	mode      stepMode
+	stepDepth int
+	entry     bool
+	detached  bool
+
+	// terminated may be set from any goroutine to stop the program at
+	// its next statement
+	terminated atomic.Bool
+
+	// evaluating is set while the frontend evaluates expressions in a
+	// paused frame, so they run without stopping
+	evaluating bool
+
+	// mainEnv and mainLine locate the top-level code, which has no frame;
+	// lastFrame and lastLine identify the previous statement, so that a
+	// breakpoint fires once per line rather than once per statement
+	mainEnv   *Environment
+	mainLine  int
+	lastFrame *Frame
+	lastLine  int
+}
+
+// NewDebugger creates a debugger that pauses before the first statement
+func NewDebugger(frontend debugFrontend) *Debugger {
+	return &Debugger{frontend: frontend, entry: true}
+}
+
+// Attach makes the debugger control programs evaluated in env's runtime.
+// Tail calls keep their frames, so they can be stepped over and show up in
+// backtraces.
+func (d *Debugger) Attach(env *Environment) {
+	env.rt.keepFrames = true
+	env.AddHooks(d)
+}
+
+// SetBreakpoint adds a breakpoint on line, with an optional condition
+func (d *Debugger) SetBreakpoint(line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{Line: line, Condition: strings.TrimSpace(condition)}
+	if bp.Condition != "" {
+		p := NewParser(New(bp.Condition))
+		bp.condition = p.parseExpression(LOWEST)
+		if len(p.Errors()) > 0 || bp.condition == nil || !p.peekTokenIs(EOF) {
+			return nil, fmt.Errorf("invalid condition %q", bp.Condition)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextBreakpointID++
+	bp.ID = d.nextBreakpointID
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp, nil
+}
+
+// ClearBreakpoint removes a breakpoint by ID, reporting whether it existed
+func (d *Debugger) ClearBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearBreakpoints removes every breakpoint
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	d.breakpoints = nil
+	d.mu.Unlock()
+}
+
+// Breakpoints returns the breakpoints ordered by ID
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Breakpoint(nil), d.breakpoints...)
+}
+
+// Continue resumes until the next breakpoint
+func (d *Debugger) Continue() {
+	d.mode = runToBreakpoint
+}
+
+// StepIn resumes until the next statement, entering calls
+func (d *Debugger) StepIn() {
+	d.mode = stepIn
+}
+
+// StepOver resumes until the next statement of the paused frame or a
+// caller, running calls without stopping in them
+func (d *Debugger) StepOver(p *Pause) {
+	d.mode = stepOver
+	d.stepDepth = frameDepth(p.Frame)
+}
+
+// StepOut resumes until the paused frame returns to its caller
+func (d *Debugger) StepOut(p *Pause) {
+	d.mode = stepOut
+	d.stepDepth = frameDepth(p.Frame)
+}
+
+// Terminate stops the program before its next statement. Unlike the
+// other methods it may be called while the program is running.
+func (d *Debugger) Terminate() {
+	d.terminated.Store(true)
+}
+
+// Detach lets the program run to completion without pausing again
+func (d *Debugger) Detach() {
+	d.detached = true
+}
+
+// Evaluate runs source as statements in the paused scope and returns the
+// value of the last one. Breakpoints do not fire while it runs.
+func (d *Debugger) Evaluate(p *Pause, source string) Object {
+	parser := NewParser(New(source))
+	program := parser.ParseProgram()
+	if len(parser.Errors()) > 0 {
+		return newError("%s", strings.Join(parser.Errors(), "; "))
+	}
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+
+	var result Object = NULL
+	for _, stmt := range program.Statements {
+		result = Eval(stmt, p.Env)
+		if isAbrupt(result) {
+			return unwrapReturnValue(resolveTailCall(result, p.Env))
+		}
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// Stack returns the call stack of a paused program, innermost first
+func (d *Debugger) Stack(p *Pause) []StackEntry {
+	var stack []StackEntry
+	for f := p.Frame; f != nil; f = f.Caller {
+		stack = append(stack, StackEntry{Name: f.Name(), Line: f.Line, Env: f.Env})
+	}
+	return append(stack, StackEntry{Name: "<main>", Line: d.mainLine, Env: d.mainEnv})
+}
+
+// frameDepth returns the call depth of a frame, 0 for top-level code
+func frameDepth(f *Frame) int {
+	if f == nil {
+		return 0
+	}
+	return f.Depth
+}
+
+// OnStatement implements EvalHooks. It returns a non-nil object when the
+// frontend asks to abort the program.
+func (d *Debugger) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	if d.terminated.Load() {
+		return newDebuggerQuit()
+	}
+
+	frame := env.frame
+	if d.detached || d.evaluating || frame != nil && frame.TaskID != 0 {
+		return nil
+	}
+
+	line := pos.Line
+	if frame == nil {
+		d.mainEnv, d.mainLine = env, line
+	} else {
+		frame.Line = line
+	}
+	newLine := frame != d.lastFrame || line != d.lastLine
+	d.lastFrame, d.lastLine = frame, line
+
+	p := &Pause{Line: line, Env: env, Frame: frame}
+	depth := frameDepth(frame)
+	switch {
+	case d.entry:
+		p.Reason = "entry"
+		d.entry = false
+	case d.mode == stepIn,
+		d.mode == stepOver && depth <= d.stepDepth,
+		d.mode == stepOut && depth < d.stepDepth:
+		p.Reason = "step"
+	}
+
+	if newLine {
+		if bp, condErr := d.breakpointHit(line, env); bp != nil {
+			p.Reason, p.Breakpoint, p.ConditionError = "breakpoint", bp, condErr
+		}
+	}
+
+	if p.Reason == "" {
+		return nil
+	}
+	d.mode = runToBreakpoint
+	return d.frontend.paused(d, p)
+}
+
+// breakpointHit returns the first breakpoint on line whose condition
+// holds in env. A condition that fails to evaluate counts as a hit, and
+// its error is returned alongside.
+func (d *Debugger) breakpointHit(line int, env *Environment) (*Breakpoint, *Error) {
+	for _, bp := range d.Breakpoints() {
+		if bp.Line != line {
+			continue
+		}
+		if bp.condition == nil {
+			bp.Hits++
+			return bp, nil
+		}
+
+		d.evaluating = true
+		value := Eval(bp.condition, env)
+		d.evaluating = false
+
+		if err, ok := value.(*Error); ok {
+			bp.Hits++
+			return bp, err
+		}
+		if isTruthy(value) {
+			bp.Hits++
+			return bp, nil
+		}
+	}
+	return nil, nil
+}
+
+// Scope is one environment in the chain visible from a paused statement
+type Scope struct {
+	Name      string
+	Variables []Variable
+}
+
+// Variable is a name bound in a scope
+type Variable struct {
+	Name  string
+	Value Object
+}
+
+// Scopes walks the environment chain from env outwards, naming each
+// scope after the call it belongs to
+func Scopes(env *Environment) []Scope {
+	var scopes []Scope
+	for e := env; e != nil; e = e.outer {
+		name := "global"
+		if e.frame != nil && e.frame.Env == e {
+			name = "locals of " + e.frame.Name()
+		} else if e.outer != nil {
+			name = "enclosing scope"
+		}
+
+		scope := Scope{Name: name}
+		for _, n := range e.Names() {
+			value, _ := e.lookup(n)
+			scope.Variables = append(scope.Variables, Variable{Name: n, Value: value})
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
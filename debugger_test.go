@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// frontendFunc adapts a function to the debugFrontend interface
+type frontendFunc func(d *Debugger, p *Pause) Object
+
+func (f frontendFunc) paused(d *Debugger, p *Pause) Object { return f(d, p) }
+
+const debugTestProgram = `func add(a, b) {
+    let sum = a + b;
+    return sum;
+}
+func twice(x) {
+    let first = add(x, x);
+    return add(first, first);
+}
+let result = twice(3);
+result;`
+
+// debugStops runs the program, resuming each pause with the next action,
+// and returns the lines it stopped at
+func debugStops(t *testing.T, input string, actions []string) []int {
+	t.Helper()
+	var stops []int
+	frontend := frontendFunc(func(d *Debugger, p *Pause) Object {
+		stops = append(stops, p.Line)
+		if len(actions) == 0 {
+			d.Detach()
+			return nil
+		}
+		action := actions[0]
+		actions = actions[1:]
+		switch action {
+		case "step":
+			d.StepIn()
+		case "next":
+			d.StepOver(p)
+		case "out":
+			d.StepOut(p)
+		default:
+			d.Continue()
+		}
+		return nil
+	})
+
+	env := NewEnvironment()
+	NewDebugger(frontend).Attach(env)
+	if result := Eval(NewParser(New(input)).ParseProgram(), env); isError(result) {
+		t.Fatalf("program failed: %s", result.Inspect())
+	}
+	return stops
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  []string
+		expected []int
+	}{
+		{"step in", []string{"step", "step", "step", "step", "step", "step"}, []int{1, 5, 9, 6, 2, 3, 7}},
+		{"step over", []string{"next", "next", "next", "next"}, []int{1, 5, 9, 10}},
+		{"step over in function", []string{"step", "step", "step", "next", "next", "next"}, []int{1, 5, 9, 6, 7, 10}},
+		{"step out", []string{"step", "step", "step", "step", "out", "out"}, []int{1, 5, 9, 6, 2, 7, 10}},
+		{"continue", []string{"continue"}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		stops := debugStops(t, debugTestProgram, tt.actions)
+		if fmt.Sprint(stops) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: stopped at %v, want %v", tt.name, stops, tt.expected)
+		}
+	}
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	var stops []string
+	var d *Debugger
+	d = NewDebugger(frontendFunc(func(d *Debugger, p *Pause) Object {
+		if p.Reason == "entry" {
+			d.SetBreakpoint(2, "")
+			d.SetBreakpoint(3, "sum > 10")
+			d.Continue()
+			return nil
+		}
+
+		a, _ := p.Env.Get("a")
+		stops = append(stops, fmt.Sprintf("%s:%d:a=%s", p.Reason, p.Line, a.Inspect()))
+		if p.Breakpoint.ID == 1 && p.Breakpoint.Hits == 2 {
+			d.ClearBreakpoint(1)
+		}
+		d.Continue()
+		return nil
+	}))
+
+	env := NewEnvironment()
+	d.Attach(env)
+	Eval(NewParser(New(debugTestProgram)).ParseProgram(), env)
+
+	expected := []string{"breakpoint:2:a=3", "breakpoint:2:a=6", "breakpoint:3:a=6"}
+	if strings.Join(stops, " ") != strings.Join(expected, " ") {
+		t.Errorf("stops wrong.\nexpected=%v\ngot=     %v", expected, stops)
+	}
+
+	if _, err := d.SetBreakpoint(1, "a +"); err == nil {
+		t.Errorf("expected error for invalid condition")
+	}
+}
+
+func TestDebuggerInspection(t *testing.T) {
+	var backtrace []string
+	var scopes []Scope
+	var evaluated string
+
+	d := NewDebugger(frontendFunc(func(d *Debugger, p *Pause) Object {
+		if p.Reason == "entry" {
+			d.SetBreakpoint(3, "")
+			d.Continue()
+			return nil
+		}
+		for _, entry := range d.Stack(p) {
+			backtrace = append(backtrace, fmt.Sprintf("%s:%d", entry.Name, entry.Line))
+		}
+		scopes = Scopes(p.Env)
+		evaluated = d.Evaluate(p, "let y = sum * 10; add(y, 1)").Inspect()
+		return newDebuggerQuit()
+	}))
+
+	env := NewEnvironment()
+	d.Attach(env)
+	result := Eval(NewParser(New(debugTestProgram)).ParseProgram(), env)
+
+	if !isDebuggerQuit(result) {
+		t.Errorf("expected program to be terminated, got %s", result.Inspect())
+	}
+	if strings.Join(backtrace, " ") != "add:3 twice:6 <main>:9" {
+		t.Errorf("wrong backtrace: %v", backtrace)
+	}
+	if len(scopes) != 2 || scopes[0].Name != "locals of add" || scopes[1].Name != "global" {
+		t.Fatalf("wrong scopes: %+v", scopes)
+	}
+	var locals []string
+	for _, v := range scopes[0].Variables {
+		locals = append(locals, v.Name+"="+v.Value.Inspect())
+	}
+	if strings.Join(locals, " ") != "a=3 b=3 sum=6" {
+		t.Errorf("wrong locals: %v", locals)
+	}
+	if evaluated != "61" {
+		t.Errorf("eval in frame gave %s, want 61", evaluated)
+	}
+}
+
+func TestDebuggerIgnoresTasks(t *testing.T) {
+	input := `func work(n) {
+    return n * 2;
+}
+let t = spawn work(21);
+await(t);`
+
+	var lines []int
+	d := NewDebugger(frontendFunc(func(d *Debugger, p *Pause) Object {
+		lines = append(lines, p.Line)
+		d.StepIn()
+		return nil
+	}))
+	env := NewEnvironment()
+	d.Attach(env)
+	result := Eval(NewParser(New(input)).ParseProgram(), env)
+
+	testIntegerObject(t, result, 42)
+	if fmt.Sprint(lines) != "[1 4 5]" {
+		t.Errorf("stopped at %v, want [1 4 5]", lines)
+	}
+}
+
+func TestRunDebug(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prog.tiny")
+	if err := os.WriteFile(file, []byte(debugTestProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	script := strings.Join([]string{
+		"break 2 if a == 6",
+		"continue",
+		"print a",
+		"print missing",
+		"eval a * 7",
+		"backtrace",
+		"next",
+		"",
+		"breakpoints",
+		"bogus",
+		"quit",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := runDebug([]string{file}, strings.NewReader(script), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+
+	expected := `TinyLang debugger. Type "help" for commands.
+line 1: func add(a, b) {
+(tdb) Breakpoint 1 at line 2 if a == 6
+(tdb) Breakpoint 1, line 2: let sum = a + b;
+(tdb) a = 6
+(tdb) No variable "missing" in scope
+(tdb) 42
+(tdb) #0 add at line 2
+#1 twice at line 7
+#2 <main> at line 9
+(tdb) line 3: return sum;
+(tdb) line 10: result;
+(tdb) 1: line 2 if a == 6 (hit 1 times)
+(tdb) Unknown command "bogus". Type "help" for commands.
+(tdb) Program terminated.
+`
+	if stdout.String() != expected {
+		t.Errorf("wrong session.\nexpected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	// Ending the input lets the program run to completion
+	stdout.Reset()
+	if code := runDebug([]string{file}, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	if !strings.HasSuffix(stdout.String(), "Program finished: 12\n") {
+		t.Errorf("wrong output: %q", stdout.String())
+	}
+}
+
+func TestDebuggerQuitPosition(t *testing.T) {
+	// quitting inside add, then at the entry of a second session
+	quit := func(line int) *Error {
+		d := NewDebugger(frontendFunc(func(d *Debugger, p *Pause) Object {
+			if p.Reason == "entry" && line > 1 {
+				d.SetBreakpoint(line, "")
+				d.Continue()
+				return nil
+			}
+			return newDebuggerQuit()
+		}))
+		env := NewEnvironment()
+		d.Attach(env)
+		result := Eval(NewParser(New(debugTestProgram)).ParseProgram(), env)
+		if !isDebuggerQuit(result) {
+			t.Fatalf("expected program to be terminated, got %s", result.Inspect())
+		}
+		return result.(*Error)
+	}
+
+	first := quit(2)
+	second := quit(1)
+	if first == second || first.Line == 0 || second.Line != 0 {
+		t.Errorf("quit errors share state: first at line %d, second at line %d", first.Line, second.Line)
+	}
+}
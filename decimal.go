@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+func init() {
+	builtins["decimal"] = &Builtin{Name: "decimal", Fn: builtinDecimal}
+	builtins["round"] = &Builtin{Name: "round", Fn: builtinRound}
+	builtins["format_decimal"] = &Builtin{Name: "format_decimal", Fn: builtinFormatDecimal}
+}
+
+// RoundingMode says how a decimal loses digits, when dividing or rounding
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // to the nearest, ties to even: 2.5 to 2, 3.5 to 4
+	RoundHalfUp                       // to the nearest, ties away from zero: 2.5 to 3
+	RoundDown                         // toward zero: 2.9 to 2, -2.9 to -2
+)
+
+// roundingModeNames are the names of the rounding modes in flags and in
+// round()
+var roundingModeNames = map[RoundingMode]string{
+	RoundHalfEven: "half-even",
+	RoundHalfUp:   "half-up",
+	RoundDown:     "down",
+}
+
+// lookupRoundingMode returns the rounding mode called name
+func lookupRoundingMode(name string) (RoundingMode, bool) {
+	for mode, modeName := range roundingModeNames {
+		if modeName == name {
+			return mode, true
+		}
+	}
+	return 0, false
+}
+
+// String implements flag.Value
+func (m *RoundingMode) String() string {
+	if m == nil {
+		return roundingModeNames[RoundHalfEven]
+	}
+	return roundingModeNames[*m]
+}
+
+// Set implements flag.Value
+func (m *RoundingMode) Set(s string) error {
+	mode, ok := lookupRoundingMode(s)
+	if !ok {
+		return fmt.Errorf("unknown rounding mode %q, want half-even, half-up or down", s)
+	}
+	*m = mode
+	return nil
+}
+
+// SetRounding selects how the run rounds decimal divisions
+func (rt *Runtime) SetRounding(mode RoundingMode) {
+	rt.rounding = mode
+}
+
+// parseDecimal parses a decimal such as "12.50" or "-3"
+func parseDecimal(s string) (*Decimal, bool) {
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || strings.Trim(whole+fraction, "0123456789") != "" || strings.HasSuffix(digits, ".") {
+		return nil, false
+	}
+	value, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return nil, false
+	}
+	if len(digits) < len(s) {
+		value.Neg(value)
+	}
+	return &Decimal{Value: value, Scale: len(fraction)}, true
+}
+
+// toDecimal converts an integer or decimal to a decimal
+func toDecimal(obj Object) (*Decimal, bool) {
+	switch obj := obj.(type) {
+	case *Decimal:
+		return obj, true
+	case *Integer:
+		return &Decimal{Value: big.NewInt(obj.Value)}, true
+	case *BigInt:
+		return &Decimal{Value: obj.Value}, true
+	}
+	return nil, false
+}
+
+// pow10 returns 10 to the power n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// rescale returns d with scale digits after the point, rounding as mode
+// says if digits are lost
+func rescale(d *Decimal, scale int, mode RoundingMode) *Decimal {
+	if scale >= d.Scale {
+		return &Decimal{Value: new(big.Int).Mul(d.Value, pow10(scale-d.Scale)), Scale: scale}
+	}
+	return &Decimal{Value: roundQuo(d.Value, pow10(d.Scale-scale), mode), Scale: scale}
+}
+
+// roundQuo divides x by y, rounding the quotient as mode says
+func roundQuo(x, y *big.Int, mode RoundingMode) *big.Int {
+	quo, rem := new(big.Int).QuoRem(x, y, new(big.Int))
+	if rem.Sign() == 0 || mode == RoundDown {
+		return quo
+	}
+	// compare the remainder with half the divisor
+	half := new(big.Int).Abs(rem)
+	half.Lsh(half, 1)
+	cmp := half.CmpAbs(y)
+	if cmp > 0 || cmp == 0 && (mode == RoundHalfUp || quo.Bit(0) == 1) {
+		if (x.Sign() < 0) != (y.Sign() < 0) {
+			return quo.Sub(quo, big.NewInt(1))
+		}
+		return quo.Add(quo, big.NewInt(1))
+	}
+	return quo
+}
+
+// alignDecimals returns the values of a and b at the same scale, which is
+// also returned
+func alignDecimals(a, b *Decimal) (*big.Int, *big.Int, int) {
+	scale := a.Scale
+	if b.Scale > scale {
+		scale = b.Scale
+	}
+	return rescale(a, scale, RoundDown).Value, rescale(b, scale, RoundDown).Value, scale
+}
+
+// decimalCmp compares two decimals by value, so 1.50 equals 1.5
+func decimalCmp(a, b *Decimal) int {
+	x, y, _ := alignDecimals(a, b)
+	return x.Cmp(y)
+}
+
+// evalDecimalInfixExpression evaluates an operator on a decimal and an
+// integer or decimal. Sums and products are exact; a quotient keeps the
+// larger scale of its operands and is rounded with the run's rounding
+// mode, so 10.00d / 3 is 3.33.
+func evalDecimalInfixExpression(operator string, left, right Object, env *Environment) Object {
+	leftDec, _ := toDecimal(left)
+	rightDec, _ := toDecimal(right)
+	leftVal, rightVal, scale := alignDecimals(leftDec, rightDec)
+
+	switch operator {
+	case "+":
+		return &Decimal{Value: new(big.Int).Add(leftVal, rightVal), Scale: scale}
+	case "-":
+		return &Decimal{Value: new(big.Int).Sub(leftVal, rightVal), Scale: scale}
+	case "*":
+		return &Decimal{Value: new(big.Int).Mul(leftDec.Value, rightDec.Value), Scale: leftDec.Scale + rightDec.Scale}
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newErrorKind(ZERO_DIVISION_ERROR, "division by zero")
+		}
+		// (x / 10^s) / (y / 10^s) at scale s is x * 10^s / y
+		return &Decimal{Value: roundQuo(new(big.Int).Mul(leftVal, pow10(scale)), rightVal, env.rt.rounding), Scale: scale}
+	case "<":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToPyBoolean(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newErrorKind(TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// negateDecimal evaluates -d
+func negateDecimal(d *Decimal) *Decimal {
+	return &Decimal{Value: new(big.Int).Neg(d.Value), Scale: d.Scale}
+}
+
+// isDecimalOperation reports whether an infix operator applies to a
+// decimal and an integer or decimal
+func isDecimalOperation(left, right Object) bool {
+	_, leftOk := toDecimal(left)
+	_, rightOk := toDecimal(right)
+	return leftOk && rightOk && (left.Type() == DECIMAL_OBJ || right.Type() == DECIMAL_OBJ)
+}
+
+// builtinDecimal converts an integer or a string such as "12.50" to a
+// decimal. Strings that do not hold a decimal produce an Err value.
+func builtinDecimal(_ *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to decimal: got=%d, want=1", len(args))
+	}
+	if s, ok := args[0].(*String); ok {
+		d, ok := parseDecimal(strings.TrimSpace(s.Value))
+		if !ok {
+			return &Err{Error: newErrorKind(VALUE_ERROR, "could not parse %q as decimal", s.Value)}
+		}
+		return d
+	}
+	if d, ok := toDecimal(args[0]); ok {
+		return d
+	}
+	return newErrorKind(TYPE_ERROR, "argument to decimal not supported, got %s", args[0].Type())
+}
+
+// decimalPlaces checks the places argument of round and format_decimal
+func decimalPlaces(name string, arg Object) (int, *Error) {
+	places, ok := arg.(*Integer)
+	if !ok {
+		return 0, newErrorKind(TYPE_ERROR, "places given to %s must be INTEGER, got %s", name, arg.Type())
+	}
+	if places.Value < 0 || places.Value > 1000 {
+		return 0, newErrorKind(VALUE_ERROR, "places given to %s must be between 0 and 1000, got %d", name, places.Value)
+	}
+	return int(places.Value), nil
+}
+
+// builtinRound implements round(number, places) and round(number, places,
+// mode), which returns a decimal with places digits after the point. The
+// mode is "half-even", "half-up" or "down" and defaults to the run's.
+func builtinRound(env *Environment, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to round: got=%d, want=2 or 3", len(args))
+	}
+	d, ok := toDecimal(args[0])
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "first argument to round must be DECIMAL or INTEGER, got %s", args[0].Type())
+	}
+	places, err := decimalPlaces("round", args[1])
+	if err != nil {
+		return err
+	}
+	mode := env.rt.rounding
+	if len(args) == 3 {
+		name, ok := args[2].(*String)
+		if !ok {
+			return newErrorKind(TYPE_ERROR, "rounding mode must be STRING, got %s", args[2].Type())
+		}
+		if mode, ok = lookupRoundingMode(name.Value); !ok {
+			return newErrorKind(VALUE_ERROR, "unknown rounding mode %q, want half-even, half-up or down", name.Value)
+		}
+	}
+	return rescale(d, places, mode)
+}
+
+// builtinFormatDecimal implements format_decimal(number, places) and
+// format_decimal(number, places, separator). It returns the number with
+// exactly places digits after the point, rounded with the run's rounding
+// mode, and the separator between groups of thousands:
+// format_decimal(1234.5d, 2, ",") is "1,234.50".
+func builtinFormatDecimal(env *Environment, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to format_decimal: got=%d, want=2 or 3", len(args))
+	}
+	d, ok := toDecimal(args[0])
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "first argument to format_decimal must be DECIMAL or INTEGER, got %s", args[0].Type())
+	}
+	places, err := decimalPlaces("format_decimal", args[1])
+	if err != nil {
+		return err
+	}
+	separator := ""
+	if len(args) == 3 {
+		s, ok := args[2].(*String)
+		if !ok {
+			return newErrorKind(TYPE_ERROR, "separator given to format_decimal must be STRING, got %s", args[2].Type())
+		}
+		separator = s.Value
+	}
+
+	text := rescale(d, places, env.rt.rounding).Inspect()
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(text, ".")
+	var out strings.Builder
+	out.WriteString(sign)
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			out.WriteString(separator)
+		}
+		out.WriteRune(digit)
+	}
+	if hasFraction {
+		out.WriteString(".")
+		out.WriteString(fraction)
+	}
+	return &String{Value: out.String()}
+}
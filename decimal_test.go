@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecimalLexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{"12.50d", []Token{{Type: DECIMAL, Literal: "12.50d"}}},
+		{"7d;", []Token{{Type: DECIMAL, Literal: "7d"}, {Type: SEMICOLON, Literal: ";"}}},
+		{"12.50", []Token{{Type: ILLEGAL, Literal: "12.50"}}},
+		{"e.kind", []Token{{Type: IDENT, Literal: "e"}, {Type: DOT, Literal: "."}, {Type: IDENT, Literal: "kind"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Errorf("%q token %d: expected %s %q, got %s %q", tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != EOF {
+			t.Errorf("%q: expected EOF, got %s", tt.input, tok.Type)
+		}
+	}
+
+	p := NewParser(New("let x = 12.50;"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "12.50 is not a number: decimals need a d suffix, as in 12.50d" {
+		t.Errorf("wrong errors for a decimal without suffix: %q", errors)
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		rounding RoundingMode
+		expected string
+	}{
+		{"12.50d", RoundHalfEven, "12.50"},
+		{"0.05d", RoundHalfEven, "0.05"},
+		{"-0.05d", RoundHalfEven, "-0.05"},
+		{"1.5d + 2.25d", RoundHalfEven, "3.75"},
+		{"1.50d - 2", RoundHalfEven, "-0.50"},
+		{"19.99d * 3", RoundHalfEven, "59.97"},
+		{"1.15d * 1.15d", RoundHalfEven, "1.3225"},
+		{"10.00d / 3", RoundHalfEven, "3.33"},
+		{"20.00d / 3", RoundHalfEven, "6.67"},
+		{"-20.00d / 3", RoundHalfEven, "-6.67"},
+		{"1d / 3", RoundHalfEven, "0"},
+		{"5.00d / 8", RoundHalfEven, "0.62"},
+		{"5.00d / 8", RoundHalfUp, "0.63"},
+		{"-5.00d / 8", RoundHalfUp, "-0.63"},
+		{"7.00d / 8", RoundHalfEven, "0.88"},
+		{"5.00d / 8", RoundDown, "0.62"},
+		{"2.99d / 1.000d", RoundDown, "2.990"},
+		{"1.50d / 0", RoundHalfEven, "ERROR: division by zero"},
+		{listFunc + "list(1.50d == 1.5d, 1.00d == 1, 1 == 1.00d, 0.5d < 1, 2 >= 2.00d, 1.01d != 1)", RoundHalfEven, "[true, true, true, true, true, true]"},
+		{"-(1.5d)", RoundHalfEven, "-1.5"},
+		{"99999999999999999999 + 0.5d", RoundHalfEven, "ERROR: integer literal 99999999999999999999 does not fit in 64 bits"},
+		{"1.5d + \"x\"", RoundHalfEven, "ERROR: type mismatch: DECIMAL + STRING"},
+		{"1.5d && true", RoundHalfEven, "true"},
+		{listFunc + "list(round(2.345d, 2), round(2.335d, 2), round(2.345d, 2, \"half-up\"), round(-2.349d, 2, \"down\"), round(7, 2))", RoundHalfEven, "[2.34, 2.34, 2.35, -2.34, 7.00]"},
+		{"round(2.345d, 2)", RoundHalfUp, "2.35"},
+		{"round(2.345d, 2, \"up\")", RoundHalfEven, "ERROR: unknown rounding mode \"up\", want half-even, half-up or down"},
+		{"round(2.345d, -1)", RoundHalfEven, "ERROR: places given to round must be between 0 and 1000, got -1"},
+		{"round(\"2\", 1)", RoundHalfEven, "ERROR: first argument to round must be DECIMAL or INTEGER, got STRING"},
+		{listFunc + "list(format_decimal(1234567.5d, 2, \",\"), format_decimal(-1234.565d, 2, \" \"), format_decimal(999, 0, \",\"), format_decimal(0.5d, 3))", RoundHalfEven, "[1,234,567.50, -1 234.56, 999, 0.500]"},
+		{"format_decimal(-1234.565d, 2)", RoundHalfUp, "-1234.57"},
+		{listFunc + "list(decimal(\"12.5\"), decimal(\" -3 \"), decimal(4), decimal(0.1d))", RoundHalfEven, "[12.5, -3, 4, 0.1]"},
+		{listFunc + "list(decimal(\"1.\"), decimal(\".5\"), decimal(\"1e3\"))", RoundHalfEven,
+			"[err(\"could not parse \\\"1.\\\" as decimal\"), err(\"could not parse \\\".5\\\" as decimal\"), err(\"could not parse \\\"1e3\\\" as decimal\")]"},
+		{listFunc + "list(int(12.99d), int(-12.99d), str(12.50d) + \"!\")", RoundHalfEven, "[12, -12, 12.50!]"},
+		{"assert_eq(1.50d, 1.5d)", RoundHalfEven, "null"},
+	}
+
+	for _, tt := range tests {
+		env := NewEnvironment()
+		env.rt.SetRounding(tt.rounding)
+		if got := Eval(NewParser(New(tt.input)).ParseProgram(), env).Inspect(); got != tt.expected {
+			t.Errorf("%s (rounding %s): expected %q, got %q", tt.input, &tt.rounding, tt.expected, got)
+		}
+	}
+}
+
+func TestDecimalMemoAndChannels(t *testing.T) {
+	input := listFunc + `
+func half(d) { print(d); return d / 2; }
+let half = memo(half);
+let c = chan("DECIMAL", 1);
+send(c, half(1.50d));
+list(half(1.50d), half(1.5d), recv(c));`
+	var out bytes.Buffer
+	env := NewEnvironment()
+	env.rt.SetOutput(&out)
+	result := Eval(NewParser(New(input)).ParseProgram(), env)
+	if out.String() != "1.50\n1.5\n" || result.Inspect() != "[0.75, 0.8, 0.75]" {
+		t.Errorf("wrong result %s with output %q", result.Inspect(), out.String())
+	}
+}
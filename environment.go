@@ -1,35 +1,152 @@
 package main
 
+import (
+	"sort"
+	"sync"
+)
+
+// envSlots is how many names a scope holds before it needs a map. Most
+// function calls bind only a few parameters and locals.
+const envSlots = 4
+
 // Environment represents a scope for variables and functions
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu     sync.RWMutex
+	slots  [envSlots]binding
+	used   int               // the slots holding a binding
+	store  map[string]Object // the names that do not fit in the slots
+	outer  *Environment
+	frozen bool
+	rt     *Runtime
+	frame  *Frame
+}
+
+// binding is a name bound in a slot of an environment
+type binding struct {
+	name  string
+	value Object
 }
 
 // NewEnvironment creates a new environment
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{outer: nil, rt: newRuntime()}
 }
 
 // NewEnclosedEnvironment creates a new environment with an outer scope
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	return &Environment{outer: outer, rt: outer.rt, frame: outer.frame}
+}
+
+// Runtime returns the state of the run this environment belongs to
+func (e *Environment) Runtime() *Runtime {
+	return e.rt
+}
+
+// Frame returns the function call this environment belongs to, or nil
+// for top-level code
+func (e *Environment) Frame() *Frame {
+	return e.frame
+}
+
+// Outer returns the enclosing environment, or nil for the outermost one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the names bound in this scope only, sorted
+func (e *Environment) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	store := make(map[string]Object, e.used+len(e.store))
+	for name, value := range e.store {
+		store[name] = value
+	}
+	for _, b := range e.slots[:e.used] {
+		store[b.name] = b.value
+	}
+	return sortedNames(store)
+}
+
+// sortedNames returns the keys of a store in order
+func sortedNames(store map[string]Object) []string {
+	names := make([]string, 0, len(store))
+	for name := range store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // Get retrieves a value from the environment
 func (e *Environment) Get(name string) (Object, bool) {
-	value, ok := e.store[name]
+	value, ok := e.lookup(name)
 	if !ok && e.outer != nil {
 		value, ok = e.outer.Get(name)
 	}
 	return value, ok
 }
 
+// lookup reads a name from this scope only. Frozen scopes never change,
+// so they are read without taking the lock.
+func (e *Environment) lookup(name string) (Object, bool) {
+	if e.frozen {
+		return e.find(name)
+	}
+	e.mu.RLock()
+	value, ok := e.find(name)
+	e.mu.RUnlock()
+	return value, ok
+}
+
+// find reads a name from the slots or the map of this scope
+func (e *Environment) find(name string) (Object, bool) {
+	for i := 0; i < e.used; i++ {
+		if e.slots[i].name == name {
+			return e.slots[i].value, true
+		}
+	}
+	value, ok := e.store[name]
+	return value, ok
+}
+
 // Set stores a value in the environment
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.frozen {
+		return newError("cannot assign %s: environment is frozen", name)
+	}
+	for i := 0; i < e.used; i++ {
+		if e.slots[i].name == name {
+			e.slots[i].value = val
+			return val
+		}
+	}
+	if e.used < envSlots {
+		e.slots[e.used] = binding{name: name, value: val}
+		e.used++
+		return val
+	}
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Freeze makes the environment immutable. A frozen environment can be
+// shared between goroutines and used as the outer scope of many runs;
+// it must be frozen before it is handed to other goroutines.
+func (e *Environment) Freeze() *Environment {
+	e.mu.Lock()
+	e.frozen = true
+	e.mu.Unlock()
+	return e
+}
+
+// Frozen reports whether the environment has been frozen
+func (e *Environment) Frozen() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.frozen
+}
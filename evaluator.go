@@ -16,72 +16,129 @@ func Eval(node Node, env *Environment) Object {
 
 	case *LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if res := env.Set(node.Name.Value, val); isError(res) {
+			return positionError(res, node.Token)
+		}
 		return NULL
 
 	case *ReturnStatement:
+		if call, ok := node.ReturnValue.(*CallExpression); ok && !env.rt.keepFrames && env.frame != nil && env.frame.Function != nil {
+			return evalTailCall(call, env)
+		}
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isAbrupt(val) {
 			return val
 		}
 		return &ReturnValue{Value: val}
 
 	case *FunctionStatement:
 		fn := &Function{
+			Name:       node.Name.Value,
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Body:       node.Body,
 			Env:        env,
 		}
-		env.Set(node.Name.Value, fn)
+		if res := env.Set(node.Name.Value, fn); isError(res) {
+			return positionError(res, node.Token)
+		}
 		return NULL
 
 	case *IfStatement:
 		return evalIfExpression(node, env)
 
+	case *TryStatement:
+		return evalTryStatement(node, env)
+
+	case *ThrowStatement:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		err := newThrownError(val)
+		// Rethrowing a caught error raises it again
+		err.reported = false
+		return positionError(err, node.Token)
+
 	// Expressions
 	case *IntegerLiteral:
-		return &Integer{Value: node.Value}
+		if node.Big != nil {
+			return positionError(evalBigIntegerLiteral(node, env), node.Token)
+		}
+		return node.Object()
 
 	case *StringLiteral:
-		return &String{Value: node.Value}
+		return node.Object()
+
+	case *DecimalLiteral:
+		return node.Value
 
 	case *BooleanLiteral:
 		return nativeBoolToPyBoolean(node.Value)
 
 	case *Identifier:
-		return evalIdentifier(node, env)
+		return positionError(evalIdentifier(node, env), node.Token)
 
 	case *PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return positionError(evalPrefixExpression(node.Operator, right, env), node.Token)
 
 	case *InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isAbrupt(left) {
 			return left
 		}
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return positionError(evalInfixExpression(node.Operator, left, right, env), node.Token)
+
+	case *PropertyExpression:
+		object := Eval(node.Object, env)
+		if isAbrupt(object) {
+			return object
+		}
+		return positionError(evalPropertyExpression(object, node.Property.Value), node.Token)
 
 	case *CallExpression:
 		function := Eval(node.Function, env)
-		if isError(function) {
+		if isAbrupt(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		args, named, abrupt := evalArguments(node.Arguments, env)
+		if abrupt != nil {
+			return abrupt
+		}
+		return callFunction(node, node.Token, function, args, named, env)
+
+	case *IndexExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isAbrupt(index) {
+			return index
 		}
-		return applyFunction(function, args)
+		return positionError(evalIndexExpression(left, index), node.Token)
+
+	case *SpawnExpression:
+		return evalSpawnExpression(node, env)
+
+	case *PropagateExpression:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return evalPropagateExpression(val)
 
 	default:
 		return newError("unknown node type: %T", node)
@@ -92,11 +149,19 @@ func Eval(node Node, env *Environment) Object {
 func evalProgram(stmts []Statement, env *Environment) Object {
 	var result Object
 
+	env.rt.enter()
+	defer env.rt.exit()
+
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = evalStatement(statement, env)
 
 		switch result := result.(type) {
 		case *ReturnValue:
+			// An Err returned or propagated with ? out of the program
+			// itself has no caller left to handle it
+			if err, ok := result.Value.(*Err); ok {
+				return &Error{Message: err.Error.Message, Kind: err.Error.Kind, Line: err.Error.Line, Column: err.Error.Column}
+			}
 			return result.Value
 		case *Error:
 			return result
@@ -106,12 +171,37 @@ func evalProgram(stmts []Statement, env *Environment) Object {
 	return result
 }
 
+// evalStatement evaluates one statement of a program or block, reporting
+// it and any error it raises to the run's hooks
+func evalStatement(stmt Statement, env *Environment) Object {
+	hooks := env.rt.hooks
+	if hooks == nil {
+		return Eval(stmt, env)
+	}
+
+	pos := statementToken(stmt)
+	if stop := hooks.OnStatement(stmt, pos, env); stop != nil {
+		// Aborting is not an error of the program
+		if err, ok := stop.(*Error); ok && !err.reported {
+			err.reported = true
+		}
+		return stop
+	}
+
+	result := Eval(stmt, env)
+	if err, ok := result.(*Error); ok && !err.reported {
+		err.reported = true
+		hooks.OnError(stmt, err, pos, env)
+	}
+	return result
+}
+
 // evalBlockStatement evaluates a block of statements
 func evalBlockStatement(block *BlockStatement, env *Environment) Object {
 	var result Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = evalStatement(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -127,10 +217,14 @@ func evalBlockStatement(block *BlockStatement, env *Environment) Object {
 // evalIfExpression evaluates if-else expressions
 func evalIfExpression(ie *IfStatement, env *Environment) Object {
 	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
+	if b, ok := env.rt.hooks.(BranchHook); ok {
+		b.OnBranch(ie, isTruthy(condition), env)
+	}
+
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
@@ -140,24 +234,128 @@ func evalIfExpression(ie *IfStatement, env *Environment) Object {
 	}
 }
 
+// evalTryStatement evaluates try/catch/finally statements. An error raised
+// in the try block is bound to the catch parameter in the current scope.
+// The finally block always runs, and a return or error from it replaces
+// the outcome of the try and catch blocks.
+func evalTryStatement(ts *TryStatement, env *Environment) Object {
+	// A call returned from the try block is still guarded by it
+	result := resolveTailCall(Eval(ts.Block, env), env)
+
+	// Exceeding a run limit stops the whole program, so it is not caught
+	if err, ok := result.(*Error); ok && ts.Catch != nil && err.Kind != LIMIT_ERROR {
+		if res := env.Set(ts.CatchParam.Value, &Err{Error: err}); isError(res) {
+			result = positionError(res, ts.CatchParam.Token)
+		} else {
+			result = Eval(ts.Catch, env)
+		}
+	}
+
+	if ts.Finally != nil {
+		result = resolveTailCall(result, env)
+		final := Eval(ts.Finally, env)
+		if final != nil {
+			rt := final.Type()
+			if rt == RETURN_OBJ || rt == ERROR_OBJ {
+				return final
+			}
+		}
+	}
+
+	return result
+}
+
+// evalIndexExpression evaluates indexing into arrays
+func evalIndexExpression(left, index Object) Object {
+	array, ok := left.(*Array)
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "index operator not supported: %s", left.Type())
+	}
+	if b, ok := index.(*BigInt); ok {
+		return newErrorKind(INDEX_ERROR, "index out of range: %s (length %d)", b.Inspect(), len(array.Elements))
+	}
+	idx, ok := index.(*Integer)
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "array index must be INTEGER, got %s", index.Type())
+	}
+	if idx.Value < 0 || idx.Value >= int64(len(array.Elements)) {
+		return newErrorKind(INDEX_ERROR, "index out of range: %d (length %d)", idx.Value, len(array.Elements))
+	}
+	return array.Elements[idx.Value]
+}
+
+// evalPropagateExpression implements the ? operator: ok values are
+// unwrapped, Err values are returned from the enclosing function and any
+// other value passes through unchanged
+func evalPropagateExpression(val Object) Object {
+	switch val := val.(type) {
+	case *Ok:
+		return val.Value
+	case *Err:
+		return &ReturnValue{Value: val}
+	default:
+		return val
+	}
+}
+
+// evalPropertyExpression evaluates property access on objects that have
+// named fields
+func evalPropertyExpression(object Object, name string) Object {
+	switch object := object.(type) {
+	case *Err:
+		switch name {
+		case "message":
+			return &String{Value: object.Error.Message}
+		case "kind":
+			return &String{Value: object.Error.Kind}
+		case "line":
+			return newInteger(int64(object.Error.Line))
+		case "column":
+			return newInteger(int64(object.Error.Column))
+		}
+	case *Ok:
+		if name == "value" {
+			return object.Value
+		}
+	}
+	return newErrorKind(TYPE_ERROR, "%s has no property %s", object.Type(), name)
+}
+
+// newThrownError converts the operand of a throw statement into an error.
+// Caught errors are rethrown as they are; any other value becomes the
+// message.
+func newThrownError(val Object) *Error {
+	if err, ok := val.(*Err); ok {
+		return err.Error
+	}
+	return newErrorKind(THROWN_ERROR, "%s", val.Inspect())
+}
+
 // evalIdentifier evaluates identifier expressions
 func evalIdentifier(node *Identifier, env *Environment) Object {
-	val, ok := env.Get(node.Value)
-	if !ok {
-		return newError("identifier not found: " + node.Value)
+	if val, ok := env.Get(node.Value); ok {
+		return val
 	}
-	return val
+
+	if builtin, ok := lookupBuiltin(node.Value); ok {
+		return builtin
+	}
+
+	return newErrorKind(NAME_ERROR, "identifier not found: "+node.Value)
 }
 
 // evalPrefixExpression evaluates prefix expressions like !x or -x
-func evalPrefixExpression(operator string, right Object) Object {
+func evalPrefixExpression(operator string, right Object, env *Environment) Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		if d, ok := right.(*Decimal); ok {
+			return negateDecimal(d)
+		}
+		return evalIntegerNegation(right, env)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newErrorKind(TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -175,21 +373,13 @@ func evalBangOperatorExpression(right Object) Object {
 	}
 }
 
-// evalMinusPrefixOperatorExpression evaluates the - prefix operator
-func evalMinusPrefixOperatorExpression(right Object) Object {
-	if right.Type() != INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
-	}
-
-	value := right.(*Integer).Value
-	return &Integer{Value: -value}
-}
-
 // evalInfixExpression evaluates infix expressions like x + y
-func evalInfixExpression(operator string, left, right Object) Object {
+func evalInfixExpression(operator string, left, right Object, env *Environment) Object {
 	switch {
 	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right, env)
+	case isDecimalOperation(left, right):
+		return evalDecimalInfixExpression(operator, left, right, env)
 	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -201,29 +391,32 @@ func evalInfixExpression(operator string, left, right Object) Object {
 	case operator == "||":
 		return evalLogicalOrExpression(left, right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newErrorKind(TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newErrorKind(TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-// evalIntegerInfixExpression evaluates integer infix expressions
-func evalIntegerInfixExpression(operator string, left, right Object) Object {
-	leftVal := left.(*Integer).Value
-	rightVal := right.(*Integer).Value
+// evalIntegerInfixExpression evaluates integer infix expressions.
+// Arithmetic that overflows 64 bits is handled as the run's OverflowMode
+// says.
+func evalIntegerInfixExpression(operator string, left, right Object, env *Environment) Object {
+	leftInt, leftOk := left.(*Integer)
+	rightInt, rightOk := right.(*Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntInfixExpression(operator, left, right)
+	}
+	leftVal, rightVal := leftInt.Value, rightInt.Value
 
 	switch operator {
-	case "+":
-		return &Integer{Value: leftVal + rightVal}
-	case "-":
-		return &Integer{Value: leftVal - rightVal}
-	case "*":
-		return &Integer{Value: leftVal * rightVal}
-	case "/":
-		if rightVal == 0 {
-			return newError("division by zero")
-		}
-		return &Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/":
+		if operator == "/" && rightVal == 0 {
+			return newErrorKind(ZERO_DIVISION_ERROR, "division by zero")
+		}
+		if result, ok := checkedIntegerInfix(operator, leftVal, rightVal); ok {
+			return newInteger(result)
+		}
+		return integerOverflow(operator, left, right, env)
 	case "<":
 		return nativeBoolToPyBoolean(leftVal < rightVal)
 	case ">":
@@ -237,7 +430,7 @@ func evalIntegerInfixExpression(operator string, left, right Object) Object {
 	case "!=":
 		return nativeBoolToPyBoolean(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s", operator)
+		return newErrorKind(TYPE_ERROR, "unknown operator: %s", operator)
 	}
 }
 
@@ -254,7 +447,7 @@ func evalStringInfixExpression(operator string, left, right Object) Object {
 	case "!=":
 		return nativeBoolToPyBoolean(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newErrorKind(TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -274,46 +467,250 @@ func evalLogicalOrExpression(left, right Object) Object {
 	return nativeBoolToPyBoolean(isTruthy(right))
 }
 
-// evalExpressions evaluates a list of expressions
-func evalExpressions(exps []Expression, env *Environment) []Object {
-	var result []Object
+// namedArgument is an argument value passed by name
+type namedArgument struct {
+	Name  string
+	Value Object
+}
+
+// evalArguments evaluates the arguments of a call, separating positional
+// arguments from named ones. A non-nil third result is an error or return
+// that ended evaluation.
+func evalArguments(exps []Expression, env *Environment) ([]Object, []namedArgument, Object) {
+	var args []Object
+	var named []namedArgument
 
 	for _, e := range exps {
+		if na, ok := e.(*NamedArgument); ok {
+			evaluated := Eval(na.Value, env)
+			if isAbrupt(evaluated) {
+				return nil, nil, evaluated
+			}
+			named = append(named, namedArgument{Name: na.Name.Value, Value: evaluated})
+			continue
+		}
+
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
-			return []Object{evaluated}
+		if isAbrupt(evaluated) {
+			return nil, nil, evaluated
 		}
-		result = append(result, evaluated)
+		args = append(args, evaluated)
+	}
+
+	return args, named, nil
+}
+
+// callFunction applies a function for the call node at pos, reporting
+// the call and its result to the run's hooks
+func callFunction(node Node, pos Token, fn Object, args []Object, named []namedArgument, env *Environment) Object {
+	hooks := env.rt.hooks
+	if hooks == nil {
+		return positionError(applyFunctionNamed(fn, args, named, env), pos)
 	}
 
+	hooks.OnCall(node, fn, args, pos, env)
+	result := positionError(applyFunctionNamed(fn, args, named, env), pos)
+	hooks.OnReturn(node, fn, result, pos, env)
 	return result
 }
 
-// applyFunction applies a function to its arguments
-func applyFunction(fn Object, args []Object) Object {
+// applyFunction applies a function to its arguments. env is the scope of
+// the call site, whose run the call belongs to.
+func applyFunction(fn Object, args []Object, env *Environment) Object {
+	return applyFunctionNamed(fn, args, nil, env)
+}
+
+// applyFunctionNamed applies a function to positional and named arguments
+func applyFunctionNamed(fn Object, args []Object, named []namedArgument, env *Environment) Object {
 	switch fn := fn.(type) {
 	case *Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		return applyTailCalls(fn, args, named, env)
+	case *Builtin:
+		if len(named) > 0 {
+			return newErrorKind(ARGUMENT_ERROR, "%s does not accept named arguments", fn.Name)
+		}
+		return fn.Fn(env, args...)
 	default:
-		return newError("not a function: %T", fn)
+		return newErrorKind(TYPE_ERROR, "not a function: %T", fn)
 	}
 }
 
-// extendFunctionEnv creates a new environment for function execution
-func extendFunctionEnv(fn *Function, args []Object) *Environment {
-	env := NewEnclosedEnvironment(fn.Env)
+// applyTailCalls applies fn and then each function its body returns a
+// tail call to, every one in a new frame of the original caller. A chain
+// of tail calls of any length thus runs in constant Go stack depth. The
+// hooks see each tail call and its return in the order nested calls would
+// produce them.
+func applyTailCalls(fn *Function, args []Object, named []namedArgument, env *Environment) Object {
+	hooks := env.rt.hooks
+	var pending []*tailCall
+	var last *tailCall
+	var result Object
+	for {
+		extendedEnv, err := extendFunctionEnv(fn, args, named, env)
+		if err != nil {
+			result = err
+			break
+		}
+		result = unwrapReturnValue(Eval(fn.Body, extendedEnv))
+		call, ok := result.(*tailCall)
+		if !ok {
+			break
+		}
+		if hooks != nil {
+			hooks.OnCall(call.node, call.fn, call.args, call.node.Token, call.env)
+			pending = append(pending, call)
+		}
+		fn, args, named, last = call.fn, call.args, call.named, call
+	}
+
+	if last != nil {
+		result = positionError(result, last.node.Token)
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		hooks.OnReturn(pending[i].node, pending[i].fn, result, pending[i].node.Token, pending[i].env)
+	}
+	return result
+}
+
+// evalTailCall evaluates a return of a call from inside a function. A
+// call to a TinyLang function is returned as a tailCall for
+// applyTailCalls to make; other calls are made right away.
+func evalTailCall(node *CallExpression, env *Environment) Object {
+	function := Eval(node.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+	args, named, abrupt := evalArguments(node.Arguments, env)
+	if abrupt != nil {
+		return abrupt
+	}
+
+	if fn, ok := function.(*Function); ok {
+		return &ReturnValue{Value: &tailCall{node: node, fn: fn, args: args, named: named, env: env}}
+	}
+	val := callFunction(node, node.Token, function, args, named, env)
+	if isAbrupt(val) {
+		return val
+	}
+	return &ReturnValue{Value: val}
+}
+
+// resolveTailCall makes the call of a returned tail call now, for returns
+// whose outcome is still needed in the current frame, such as those
+// inside a try block
+func resolveTailCall(obj Object, env *Environment) Object {
+	rv, ok := obj.(*ReturnValue)
+	if !ok {
+		return obj
+	}
+	call, ok := rv.Value.(*tailCall)
+	if !ok {
+		return obj
+	}
+	val := callFunction(call.node, call.node.Token, call.fn, call.args, call.named, env)
+	if isAbrupt(val) {
+		return val
+	}
+	return &ReturnValue{Value: val}
+}
+
+// callScope is the environment and frame of a function call, allocated
+// together
+type callScope struct {
+	env   Environment
+	frame Frame
+}
+
+// extendFunctionEnv creates a new environment and frame for a call made
+// from the caller's environment and binds the arguments to the
+// parameters. Every parameter must receive a value, either from the call
+// or from its default, which is evaluated in the new environment so it can
+// refer to earlier parameters.
+func extendFunctionEnv(fn *Function, args []Object, named []namedArgument, caller *Environment) (*Environment, Object) {
+	scope := &callScope{}
+	env := &scope.env
+	env.outer, env.rt = fn.Env, caller.rt
+	scope.frame = newFrame(fn, caller.frame, env)
+	env.frame = &scope.frame
+
+	if len(args) > len(fn.Parameters) && fn.Rest == nil {
+		return nil, newArityError(fn, len(args)+len(named))
+	}
+
+	// Positional arguments are bound in place; named ones need a slice
+	// holding the argument of each parameter
+	bound := args
+	if len(named) > 0 {
+		bound = make([]Object, len(fn.Parameters))
+		copy(bound, args)
+		for _, arg := range named {
+			paramIdx := -1
+			for i, param := range fn.Parameters {
+				if param.Value == arg.Name {
+					paramIdx = i
+					break
+				}
+			}
+			if paramIdx < 0 {
+				return nil, newErrorKind(ARGUMENT_ERROR, "unknown argument %s in call to %s", arg.Name, fn.Name)
+			}
+			if bound[paramIdx] != nil {
+				return nil, newErrorKind(ARGUMENT_ERROR, "argument %s given more than once in call to %s", arg.Name, fn.Name)
+			}
+			bound[paramIdx] = arg.Value
+		}
+	}
 
 	for paramIdx, param := range fn.Parameters {
-		if paramIdx >= len(args) {
-			env.Set(param.Value, NULL)
-		} else {
-			env.Set(param.Value, args[paramIdx])
+		var val Object
+		if paramIdx < len(bound) {
+			val = bound[paramIdx]
+		}
+		if val == nil {
+			if paramIdx >= len(fn.Defaults) || fn.Defaults[paramIdx] == nil {
+				if len(named) == 0 {
+					return nil, newArityError(fn, len(args))
+				}
+				return nil, newErrorKind(ARGUMENT_ERROR, "missing argument %s in call to %s", param.Value, fn.Name)
+			}
+			val = Eval(fn.Defaults[paramIdx], env)
+			if isAbrupt(val) {
+				// a ? in a default returns from the called function, not
+				// from its caller
+				return nil, unwrapReturnValue(val)
+			}
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &Array{Elements: rest})
+	}
+
+	return env, nil
+}
+
+// newArityError reports a call with the wrong number of arguments
+func newArityError(fn *Function, got int) *Error {
+	required := 0
+	for paramIdx := range fn.Parameters {
+		if paramIdx >= len(fn.Defaults) || fn.Defaults[paramIdx] == nil {
+			required++
 		}
 	}
 
-	return env
+	switch {
+	case fn.Rest != nil:
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to %s: got=%d, want at least %d", fn.Name, got, required)
+	case required == len(fn.Parameters):
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to %s: got=%d, want=%d", fn.Name, got, required)
+	default:
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to %s: got=%d, want %d to %d", fn.Name, got, required, len(fn.Parameters))
+	}
 }
 
 // unwrapReturnValue unwraps return values
@@ -334,6 +731,34 @@ func nativeBoolToPyBoolean(input bool) *Boolean {
 	return RUNTIME_FALSE
 }
 
+// positionError records the position of tok on an error that has not been
+// positioned yet, so errors point at the innermost expression that failed.
+// Err values are positioned at the call that produced them.
+func positionError(obj Object, tok Token) Object {
+	err, ok := obj.(*Error)
+	if !ok {
+		if e, isErr := obj.(*Err); isErr {
+			err = e.Error
+		}
+	}
+	if err != nil && err.Line == 0 {
+		err.Line = tok.Line
+		err.Column = tok.Column
+	}
+	return obj
+}
+
+// isAbrupt checks if an object ends evaluation of the enclosing
+// expression, which is the case for errors and for returns raised by
+// the ? operator
+func isAbrupt(obj Object) bool {
+	if obj != nil {
+		rt := obj.Type()
+		return rt == ERROR_OBJ || rt == RETURN_OBJ
+	}
+	return false
+}
+
 // isError checks if an object is an error
 func isError(obj Object) bool {
 	if obj != nil {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -298,6 +300,525 @@ func TestComparisonOperators(t *testing.T) {
 	}
 }
 
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len(1)`, "argument to len not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to len: got=2, want=1"},
+		{`int("42")`, 42},
+		{`int("abc").message`, `could not parse "abc" as integer`},
+		{`str(42) + "!"`, "42!"},
+		{`len(str(12345))`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			case *String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("unexpected object for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestTryCatchFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 / 0; } catch (e) { e.message; }`, "division by zero"},
+		{`try { 1 / 0; } catch (e) { e.kind; }`, "ZeroDivisionError"},
+		{`try { missing; } catch (e) { e.kind; }`, "NameError"},
+		{`try { "a" - "b"; } catch (e) { e.kind; }`, "TypeError"},
+		{`try { len(); } catch (e) { e.kind; }`, "ArgumentError"},
+		{`try { throw "bad input"; } catch (e) { e.message; }`, "bad input"},
+		{`try { throw "bad input"; } catch (e) { e.kind; }`, "Error"},
+		{`try { throw 42; } catch (e) { e.message; }`, "42"},
+		{`try { 5; } catch (e) { 10; }`, 5},
+		{"try {\n\n  throw \"x\";\n} catch (e) { e.line; }", 3},
+		{"let x = 1;\ntry { x + true; } catch (e) { e.column; }", 9},
+		{`let log = ""; try { throw "x"; } catch (e) { let log = "caught"; } finally { let log = log + " done"; } log;`, "caught done"},
+		{`let a = 0; try { let a = 1; } finally { let a = a + 10; } a;`, 11},
+		{`try { try { throw "inner"; } finally { let f = 1; } } catch (e) { e.message; }`, "inner"},
+		{`try { try { throw "inner"; } catch (e) { throw e; } } catch (e) { e.message; }`, "inner"},
+		{`try { try { throw "inner"; } catch (e) { throw "outer"; } } catch (e) { e.message; }`, "outer"},
+		{`try { throw "x"; } catch (e) { 1; } finally { 2; }`, 1},
+		{`try { throw "x"; } catch (e) { let saved = e; } saved.message;`, "x"},
+		{`try { throw "x"; } catch (e) { str(e); }`, `err("x")`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*String)
+			if !ok {
+				t.Errorf("object is not String for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("wrong string for %q. expected=%q, got=%q", tt.input, expected, str.Value)
+			}
+		}
+	}
+}
+
+func TestFinallyAcrossReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+let cleanups = 0;
+func work() {
+	try {
+		return 1;
+	} finally {
+		let cleanups = cleanups + 1;
+	}
+	return 2;
+}
+work();
+`, 1},
+		{`
+func work() {
+	try {
+		return 1;
+	} finally {
+		return 3;
+	}
+}
+work();
+`, 3},
+		{`
+func work() {
+	try {
+		throw "failed";
+	} catch (e) {
+		return 7;
+	} finally {
+		let ignored = 0;
+	}
+	return 0;
+}
+work();
+`, 7},
+		{`
+func risky(x) {
+	if (x > 2) {
+		throw "too big";
+	}
+	return x;
+}
+func safe(x) {
+	try {
+		return risky(x);
+	} catch (e) {
+		return -1;
+	}
+}
+safe(1) + safe(5);
+`, 0},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+func countDigits(n, acc = 1) {
+	if (n < 10) { return acc; }
+	return countDigits(n / 10, acc + 1);
+}
+countDigits(1234567890123);
+`, 13},
+		{`
+func sum(n, acc) {
+	if (n == 0) { return acc; }
+	return sum(n - 1, acc + n);
+}
+sum(200000, 0);
+`, 20000100000},
+		{`
+func isEven(n) { if (n == 0) { return true; } return isOdd(n - 1); }
+func isOdd(n) { if (n == 0) { return false; } return isEven(n - 1); }
+isEven(100001);
+`, false},
+		{`
+func down(n) {
+	if (n == 0) { return 1 / n; }
+	return down(n - 1);
+}
+down(100000);
+`, "3:25: division by zero"},
+		{`
+func down(n) {
+	if (n == 0) { throw "bottom"; }
+	return down(n - 1);
+}
+func safe() {
+	try {
+		return down(1000);
+	} catch (e) {
+		return e.message;
+	}
+}
+safe();
+`, "bottom"},
+		{`
+func one(a) { return a; }
+func f() { return one(); }
+f();
+`, "3:22: wrong number of arguments to one: got=0, want=1"},
+		{`
+func f(n) { return len(str(n)); }
+f(1);
+`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch obj := evaluated.(type) {
+			case *Error:
+				if got := fmt.Sprintf("%d:%d: %s", obj.Line, obj.Column, obj.Message); got != expected {
+					t.Errorf("wrong error. expected=%q, got=%q", expected, got)
+				}
+			case *String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("unexpected object for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestTailCallDepth(t *testing.T) {
+	input := `
+func loop(n) {
+	if (n == 0) { return "done"; }
+	return loop(n - 1);
+}
+loop(1000);
+`
+	result := runWithHooks(input, &Limits{MaxDepth: 2})
+	if str, ok := result.(*String); !ok || str.Value != "done" {
+		t.Errorf("tail calls should not deepen the call stack. got=%s", result.Inspect())
+	}
+}
+
+func TestUncaughtErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedMsg  string
+		expectedKind string
+		expectedLine int
+	}{
+		{"let a = 1;\nthrow \"boom\";", "boom", THROWN_ERROR, 2},
+		{"func f() {\n  return 1 / 0;\n}\nf();", "division by zero", ZERO_DIVISION_ERROR, 2},
+		{"try { throw \"a\"; } finally {\n  throw \"b\";\n}", "b", THROWN_ERROR, 2},
+		{"let e = 5;\ne.message;", "INTEGER has no property message", TYPE_ERROR, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMsg {
+			t.Errorf("wrong message. expected=%q, got=%q", tt.expectedMsg, errObj.Message)
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong kind. expected=%q, got=%q", tt.expectedKind, errObj.Kind)
+		}
+		if errObj.Line != tt.expectedLine {
+			t.Errorf("wrong line for %q. expected=%d, got=%d", tt.input, tt.expectedLine, errObj.Line)
+		}
+	}
+}
+
+func TestResultValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int("abc").kind`, "ValueError"},
+		{`is_err(int("abc"))`, true},
+		{`is_ok(int("12"))`, true},
+		{`ok(5).value`, 5},
+		{`str(ok(5))`, "ok(5)"},
+		{`str(err("bad"))`, `err("bad")`},
+		{`err("bad").kind`, "Error"},
+		{"let x = 1;\nlet e = int(\"x\");\ne.line;", 2},
+		{`is_ok(err("bad"))`, false},
+		{`err(err("same")).message`, "same"},
+		{`func parse(s) { return int(s)? + 1; } parse("41");`, 42},
+		{`func parse(s) { return int(s)? + 1; } parse("x").message;`, `could not parse "x" as integer`},
+		{`func half(n) { if (n / 2 * 2 != n) { return err("odd"); } return ok(n / 2); } func quarter(n) { let h = half(n)?; return ok(half(h)?); } quarter(8).value;`, 2},
+		{`func half(n) { if (n / 2 * 2 != n) { return err("odd"); } return ok(n / 2); } func quarter(n) { let h = half(n)?; return ok(half(h)?); } quarter(6).message;`, "odd"},
+		{`func f() { let a = err("early")?; return "late"; } f().message;`, "early"},
+		{`func f() { return str(int("12")?) + "!"; } f();`, "12!"},
+		{`5?`, 5},
+		{`int("oops")?; 10;`, `ERROR: could not parse "oops" as integer`},
+		{`let r = int("x")?; 5;`, `ERROR: could not parse "x" as integer`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"func max(a, b) { if (a > b) { return a; } return b; } max(1);", "wrong number of arguments to max: got=1, want=2"},
+		{"func max(a, b) { if (a > b) { return a; } return b; } max(1, 2, 3);", "wrong number of arguments to max: got=3, want=2"},
+		{"func none() { return 1; } none(1);", "wrong number of arguments to none: got=1, want=0"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet("Bob");`, "Hi, Bob"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet("Bob", "Hello");`, "Hello, Bob"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet();`, "wrong number of arguments to greet: got=0, want 1 to 2"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet(name: "Bob");`, "Hi, Bob"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet(greeting: "Yo", name: "Al");`, "Yo, Al"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet("Bob", name: "Al");`, "argument name given more than once in call to greet"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet(nam: "Al");`, "unknown argument nam in call to greet"},
+		{`func greet(name, greeting = "Hi") { return greeting + ", " + name; } greet(greeting: "Yo");`, "missing argument name in call to greet"},
+		{"func area(w, h = w) { return w * h; } area(3);", 9},
+		{`func f(x = int("a")?) { return x; } func g() { let r = f(); if (is_err(r)) { return "caller continued"; } return "no error"; } g();`, "caller continued"},
+		{"func sum(...nums) { return sumFrom(nums, 0); } func sumFrom(nums, i) { if (i == len(nums)) { return 0; } return nums[i] + sumFrom(nums, i + 1); } sum(1, 2, 3, 4);", 10},
+		{"func count(first, ...others) { return len(others); } count(1);", 0},
+		{"func count(first, ...others) { return len(others); } count(1, 2, 3);", 2},
+		{"func count(first, ...others) { return len(others); } count();", "wrong number of arguments to count: got=0, want at least 1"},
+		{`len(s: "abc");`, "len does not accept named arguments"},
+		{"func f(a) { return a; } f(missing);", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			case *String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("unexpected object for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestArityErrorPosition(t *testing.T) {
+	input := `func max(a, b) {
+	return a;
+}
+let x = 1;
+let y = max(x);`
+
+	errObj, ok := testEval(input).(*Error)
+	if !ok {
+		t.Fatalf("expected error for short call")
+	}
+	if errObj.Kind != ARGUMENT_ERROR || errObj.Line != 5 {
+		t.Errorf("wrong error. kind=%q line=%d", errObj.Kind, errObj.Line)
+	}
+}
+
+// listFunc defines list(...), which returns its arguments as an array
+const listFunc = "func list(...items) { return items; }\n"
+
+func TestArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"list(1, 2 * 2, 3 + 3)[1]", 4},
+		{"let a = list(1, 2, 3); a[0] + a[2];", 4},
+		{"len(list(1, 2, 3))", 3},
+		{"len(list())", 0},
+		{"str(list(1, \"a\", true))", `[1, a, true]`},
+		{"list(1, 2)[2]", "index out of range: 2 (length 2)"},
+		{"list(1, 2)[-1]", "index out of range: -1 (length 2)"},
+		{"5[0]", "index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(listFunc + tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			case *String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			default:
+				t.Errorf("unexpected object for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	factorial := "func factorial(n) { if (n <= 1) { return 1; } return n * factorial(n - 1); }\n"
+	tests := []struct {
+		input    string
+		mode     OverflowMode
+		expected string
+	}{
+		{"9223372036854775807 + 1", OverflowError, "1:21: OverflowError: integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", OverflowError, "1:22: OverflowError: integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", OverflowError, "1:21: OverflowError: integer overflow: 4611686018427387904 * 2"},
+		{"let min = -9223372036854775807 - 1; min * -1", OverflowError, "1:41: OverflowError: integer overflow: -9223372036854775808 * -1"},
+		{"let min = -9223372036854775807 - 1; min / -1", OverflowError, "1:41: OverflowError: integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", OverflowError, "1:37: OverflowError: integer overflow: -(-9223372036854775808)"},
+		{"99999999999999999999", OverflowError, "1:1: OverflowError: integer literal 99999999999999999999 does not fit in 64 bits"},
+		{"-9223372036854775808", OverflowError, "-9223372036854775808"},
+		{"-9223372036854775808 - 1", OverflowError, "1:22: OverflowError: integer overflow: -9223372036854775808 - 1"},
+		{"9223372036854775808", OverflowError, "1:1: OverflowError: integer literal 9223372036854775808 does not fit in 64 bits"},
+		{"--9223372036854775808", OverflowError, "1:1: OverflowError: integer overflow: -(-9223372036854775808)"},
+		{factorial + "factorial(25)", OverflowError, "1:56: OverflowError: integer overflow: 21 * 2432902008176640000"},
+		{"9223372036854775807 - 1 + 1", OverflowError, "9223372036854775807"},
+		{"-4611686018427387904 * 2", OverflowError, "-9223372036854775808"},
+
+		{factorial + "factorial(25)", OverflowBigInt, "15511210043330985984000000"},
+		{factorial + "factorial(25) / factorial(24)", OverflowBigInt, "25"},
+		{factorial + "-factorial(30) / 31", OverflowBigInt, "-8556543864909388988268015483870"},
+		{"99999999999999999999 - 99999999999999999998", OverflowBigInt, "1"},
+		{"let min = -9223372036854775807 - 1; -min", OverflowBigInt, "9223372036854775808"},
+		{listFunc + "list(99999999999999999999 > 1, 1 < 99999999999999999999, 99999999999999999999 == 99999999999999999999, 99999999999999999999 != 1)", OverflowBigInt, "[true, true, true, true]"},
+		{"99999999999999999999 / 0", OverflowBigInt, "1:22: ZeroDivisionError: division by zero"},
+		{"str(99999999999999999999) + \"!\"", OverflowBigInt, "99999999999999999999!"},
+		{"int(\"99999999999999999999\") + 1", OverflowBigInt, "100000000000000000000"},
+		{"int(\"99999999999999999999\")", OverflowError, "err(\"99999999999999999999 does not fit in 64 bits\")"},
+		{listFunc + "list(1)[99999999999999999999]", OverflowBigInt, "2:8: IndexError: index out of range: 99999999999999999999 (length 1)"},
+	}
+
+	for _, tt := range tests {
+		env := NewEnvironment()
+		env.rt.SetOverflow(tt.mode)
+		evaluated := Eval(NewParser(New(tt.input)).ParseProgram(), env)
+		got := evaluated.Inspect()
+		if err, ok := evaluated.(*Error); ok {
+			got = fmt.Sprintf("%d:%d: %s: %s", err.Line, err.Column, err.Kind, err.Message)
+		}
+		if got != tt.expected {
+			t.Errorf("%q (mode %s): expected %q, got %q", tt.input, &tt.mode, tt.expected, got)
+		}
+	}
+}
+
+func TestIntegerCache(t *testing.T) {
+	if newInteger(7) != newInteger(3+4) || newInteger(-128) != newInteger(-128) {
+		t.Errorf("small integers should be shared")
+	}
+	if newInteger(1025) == newInteger(1025) {
+		t.Errorf("large integers should not be shared")
+	}
+	for _, v := range []int64{-129, -128, 0, 1024, 1025} {
+		if got := newInteger(v).Value; got != v {
+			t.Errorf("newInteger(%d) has value %d", v, got)
+		}
+	}
+
+	program := NewParser(New("100000 - 99990 + 5;")).ParseProgram()
+	env := NewEnvironment()
+	Eval(program, env)
+	allocs := testing.AllocsPerRun(100, func() {
+		testIntegerObject(t, Eval(program, env), 15)
+	})
+	if allocs > 0 {
+		t.Errorf("evaluating literals and small results allocated %.0f times", allocs)
+	}
+}
+
+func TestEnvironmentSlots(t *testing.T) {
+	env := NewEnclosedEnvironment(NewEnvironment())
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	for i, name := range names {
+		env.Set(name, newInteger(int64(i)))
+	}
+	env.Set("b", newInteger(10))
+	env.Set("f", newInteger(50))
+
+	for name, expected := range map[string]int64{"a": 0, "b": 10, "d": 3, "e": 4, "f": 50} {
+		val, ok := env.Get(name)
+		if !ok {
+			t.Errorf("%s not found", name)
+			continue
+		}
+		testIntegerObject(t, val, expected)
+	}
+	if _, ok := env.Get("g"); ok {
+		t.Errorf("g should not be bound")
+	}
+	if got := strings.Join(env.Names(), " "); got != "a b c d e f" {
+		t.Errorf("wrong names: %q", got)
+	}
+}
+
 // Helper functions
 
 func testEval(input string) Object {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runFmt implements "tinylang fmt [-w] [-d] [files...]". Without files it
+// formats standard input. It returns the process exit code.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to the source file instead of standard output")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tinylang fmt [-w] [-d] [file.tiny ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "fmt: cannot use -w with standard input")
+			return exitUsage
+		}
+		source, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "fmt: %v\n", err)
+			return exitUsage
+		}
+		return formatSource("<stdin>", string(source), false, *diff, stdout, stderr)
+	}
+
+	status := exitOK
+	for _, filename := range flags.Args() {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(stderr, "fmt: %v\n", err)
+			status = exitUsage
+			continue
+		}
+		if code := formatSource(filename, string(source), *write, *diff, stdout, stderr); code != exitOK {
+			status = code
+		}
+	}
+	return status
+}
+
+// formatSource formats one file's source and writes, diffs or prints it
+func formatSource(filename, source string, write, diff bool, stdout, stderr io.Writer) int {
+	formatted, err := Format(source)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", filename, err)
+		return exitParseError
+	}
+
+	if diff {
+		fmt.Fprint(stdout, unifiedDiff(filename, source, formatted))
+	}
+
+	if write {
+		if formatted == source {
+			return exitOK
+		}
+		if err := os.WriteFile(filename, []byte(formatted), 0644); err != nil {
+			fmt.Fprintf(stderr, "fmt: %v\n", err)
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	if !diff {
+		fmt.Fprint(stdout, formatted)
+	}
+	return exitOK
+}
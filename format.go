@@ -0,0 +1,444 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatIndent is the indentation of one block level in formatted code
+const formatIndent = "    "
+
+// highestPrecedence ranks literals, identifiers and other expressions that
+// never need parentheses
+const highestPrecedence = INDEX + 1
+
+// Format parses source and renders it in canonical TinyLang style: one
+// statement per line, four-space indentation, parentheses only where the
+// precedences table requires them, and comments kept in place.
+func Format(source string) (string, error) {
+	lexer := New(source)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+
+	if len(parser.Errors()) > 0 {
+		return "", fmt.Errorf("parse errors: %s", strings.Join(parser.Errors(), "; "))
+	}
+
+	p := &printer{comments: lexer.Comments()}
+	p.printStatements(program.Statements, 0)
+	p.flushComments(0, 0)
+
+	return p.out.String(), nil
+}
+
+// printer renders statements and interleaves the source comments with them
+type printer struct {
+	out      strings.Builder
+	comments []Comment
+	next     int
+
+	// lastLine is the source line of the last thing written, used to
+	// keep a single blank line wherever the source had one or more
+	lastLine int
+
+	// continuing is set when a closing brace has been held back so that
+	// the next clause, like else, starts on the same line
+	continuing bool
+}
+
+// line writes one indented line of output
+func (p *printer) line(indent int, text string) {
+	p.out.WriteString(strings.Repeat(formatIndent, indent))
+	p.out.WriteString(text)
+	p.out.WriteString("\n")
+}
+
+// separate writes a blank line if the source had a gap before line
+func (p *printer) separate(line int) {
+	if p.lastLine > 0 && line > p.lastLine+1 {
+		p.out.WriteString("\n")
+	}
+}
+
+// flushComments writes the comments that start before line on lines of
+// their own. A line of zero flushes every remaining comment.
+func (p *printer) flushComments(line int, indent int) {
+	for p.next < len(p.comments) {
+		c := p.comments[p.next]
+		if line > 0 && c.Line >= line {
+			return
+		}
+		p.separate(c.Line)
+		p.line(indent, c.Text)
+		p.lastLine = c.Line
+		p.next++
+	}
+}
+
+// trailingComments returns the trailing comments on or before line,
+// formatted to follow code on the same output line
+func (p *printer) trailingComments(line int) string {
+	var out strings.Builder
+	for p.next < len(p.comments) {
+		c := p.comments[p.next]
+		if !c.Trailing || c.Line > line {
+			break
+		}
+		out.WriteString(" ")
+		out.WriteString(c.Text)
+		p.next++
+	}
+	return out.String()
+}
+
+// printStatements writes a sequence of statements at the given indentation
+func (p *printer) printStatements(stmts []Statement, indent int) {
+	for _, stmt := range stmts {
+		start := statementLine(stmt)
+		p.flushComments(start, indent)
+		p.separate(start)
+		p.printStatement(stmt, indent)
+		p.lastLine = nodeEndLine(stmt)
+	}
+}
+
+// printStatement writes a single statement and its trailing comments
+func (p *printer) printStatement(stmt Statement, indent int) {
+	switch s := stmt.(type) {
+	case *LetStatement:
+		p.simple(indent, "let "+s.Name.Value+" = "+formatExpression(s.Value)+";", s)
+	case *ReturnStatement:
+		if s.ReturnValue == nil {
+			p.simple(indent, "return;", s)
+		} else {
+			p.simple(indent, "return "+formatExpression(s.ReturnValue)+";", s)
+		}
+	case *ThrowStatement:
+		p.simple(indent, "throw "+formatExpression(s.Value)+";", s)
+	case *ExpressionStatement:
+		p.simple(indent, formatExpression(s.Expression)+";", s)
+	case *FunctionStatement:
+		header := "func " + s.Name.Value + "(" + formatParameterList(s) + ")"
+		p.block(indent, header, s.Body, "")
+	case *IfStatement:
+		header := "if (" + formatExpression(s.Condition) + ")"
+		if s.Alternative == nil {
+			p.block(indent, header, s.Consequence, "")
+		} else {
+			p.block(indent, header, s.Consequence, "}")
+			p.block(indent, "else", s.Alternative, "")
+		}
+	case *TryStatement:
+		p.printTry(s, indent)
+	case *BlockStatement:
+		p.block(indent, "", s, "")
+	}
+}
+
+// printTry writes a try statement with its catch and finally clauses
+func (p *printer) printTry(s *TryStatement, indent int) {
+	closing := ""
+	if s.Catch != nil || s.Finally != nil {
+		closing = "}"
+	}
+	p.block(indent, "try", s.Block, closing)
+
+	if s.Catch != nil {
+		closing = ""
+		if s.Finally != nil {
+			closing = "}"
+		}
+		p.block(indent, "catch ("+s.CatchParam.Value+")", s.Catch, closing)
+	}
+
+	if s.Finally != nil {
+		p.block(indent, "finally", s.Finally, "")
+	}
+}
+
+// simple writes a one-line statement followed by its trailing comments
+func (p *printer) simple(indent int, text string, stmt Statement) {
+	p.line(indent, text+p.trailingComments(nodeEndLine(stmt)))
+}
+
+// block writes header followed by a braced block. When continued is "}"
+// the closing brace is left open for a following clause like else, which
+// block then writes on the same line.
+func (p *printer) block(indent int, header string, body *BlockStatement, continued string) {
+	opening := "{"
+	if header != "" {
+		opening = header + " {"
+	}
+	if p.continuing {
+		opening = "} " + opening
+		p.continuing = false
+	}
+
+	trailing := p.trailingComments(body.Token.Line)
+	if len(body.Statements) == 0 && !p.hasCommentsBefore(body.RBrace.Line) {
+		if continued == "}" {
+			p.line(indent, opening+trailing)
+			p.continuing = true
+			return
+		}
+		p.line(indent, opening+"}"+p.trailingComments(body.RBrace.Line))
+		return
+	}
+
+	p.line(indent, opening+trailing)
+	p.lastLine = body.Token.Line
+	p.printStatements(body.Statements, indent+1)
+	p.flushComments(body.RBrace.Line, indent+1)
+	p.lastLine = body.RBrace.Line
+
+	if continued == "}" {
+		p.continuing = true
+		return
+	}
+	p.line(indent, "}"+p.trailingComments(body.RBrace.Line))
+}
+
+// hasCommentsBefore reports whether a pending comment starts before line
+func (p *printer) hasCommentsBefore(line int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Line < line
+}
+
+// formatParameterList renders the parameters of a function declaration
+func formatParameterList(fs *FunctionStatement) string {
+	params := []string{}
+	for i, param := range fs.Parameters {
+		if i < len(fs.Defaults) && fs.Defaults[i] != nil {
+			params = append(params, param.Value+" = "+formatExpression(fs.Defaults[i]))
+		} else {
+			params = append(params, param.Value)
+		}
+	}
+	if fs.Rest != nil {
+		params = append(params, "..."+fs.Rest.Value)
+	}
+	return strings.Join(params, ", ")
+}
+
+// formatExpression renders an expression with the fewest parentheses that
+// preserve its structure
+func formatExpression(exp Expression) string {
+	switch e := exp.(type) {
+	case *Identifier:
+		return e.Value
+	case *IntegerLiteral:
+		return e.Token.Literal
+	case *StringLiteral:
+		return `"` + e.Value + `"`
+	case *DecimalLiteral:
+		return e.Token.Literal
+	case *BooleanLiteral:
+		return e.Token.Literal
+	case *PrefixExpression:
+		return e.Operator + formatOperand(e.Right, PREFIX)
+	case *InfixExpression:
+		prec := expressionPrecedence(e)
+		// Operators are left-associative, so a right operand of the same
+		// precedence keeps its parentheses.
+		return formatOperand(e.Left, prec) + " " + e.Operator + " " + formatOperand(e.Right, prec+1)
+	case *CallExpression:
+		return formatOperand(e.Function, CALL) + "(" + formatExpressionList(e.Arguments) + ")"
+	case *NamedArgument:
+		return e.Name.Value + ": " + formatExpression(e.Value)
+	case *IndexExpression:
+		return formatOperand(e.Left, CALL) + "[" + formatExpression(e.Index) + "]"
+	case *PropertyExpression:
+		return formatOperand(e.Object, CALL) + "." + e.Property.Value
+	case *PropagateExpression:
+		return formatOperand(e.Value, CALL) + "?"
+	case *SpawnExpression:
+		return "spawn " + formatExpression(e.Call)
+	case nil:
+		return ""
+	default:
+		return exp.String()
+	}
+}
+
+// formatOperand renders an operand, parenthesized when it binds less
+// tightly than min
+func formatOperand(exp Expression, min int) string {
+	if expressionPrecedence(exp) < min {
+		return "(" + formatExpression(exp) + ")"
+	}
+	return formatExpression(exp)
+}
+
+// formatExpressionList renders a comma-separated list of expressions
+func formatExpressionList(exps []Expression) string {
+	out := []string{}
+	for _, e := range exps {
+		out = append(out, formatExpression(e))
+	}
+	return strings.Join(out, ", ")
+}
+
+// expressionPrecedence returns how tightly an expression binds, using the
+// parser's precedences table
+func expressionPrecedence(exp Expression) int {
+	switch e := exp.(type) {
+	case *InfixExpression:
+		if prec, ok := precedences[e.Token.Type]; ok {
+			return prec
+		}
+		return LOWEST
+	case *PrefixExpression, *SpawnExpression:
+		return PREFIX
+	case *CallExpression, *PropertyExpression, *PropagateExpression:
+		return CALL
+	case *IndexExpression:
+		return INDEX
+	default:
+		return highestPrecedence
+	}
+}
+
+// statementLine returns the line a statement starts on
+func statementLine(stmt Statement) int {
+	switch s := stmt.(type) {
+	case *ExpressionStatement:
+		return nodeStartLine(s.Expression, s.Token.Line)
+	case *LetStatement:
+		return s.Token.Line
+	case *FunctionStatement:
+		return s.Token.Line
+	case *ReturnStatement:
+		return s.Token.Line
+	case *IfStatement:
+		return s.Token.Line
+	case *TryStatement:
+		return s.Token.Line
+	case *ThrowStatement:
+		return s.Token.Line
+	case *BlockStatement:
+		return s.Token.Line
+	}
+	return 0
+}
+
+// nodeStartLine returns the first line of an expression
+func nodeStartLine(exp Expression, fallback int) int {
+	switch e := exp.(type) {
+	case *InfixExpression:
+		return nodeStartLine(e.Left, fallback)
+	case *CallExpression:
+		return nodeStartLine(e.Function, fallback)
+	case *IndexExpression:
+		return nodeStartLine(e.Left, fallback)
+	case *PropertyExpression:
+		return nodeStartLine(e.Object, fallback)
+	case *PropagateExpression:
+		return nodeStartLine(e.Value, fallback)
+	}
+	return fallback
+}
+
+// nodeEndLine returns the last source line a node is known to occupy
+func nodeEndLine(node Node) int {
+	end := 0
+	walkTokens(node, func(tok Token) {
+		line := tok.Line + strings.Count(tok.Literal, "\n")
+		if line > end {
+			end = line
+		}
+	})
+	return end
+}
+
+// walkTokens calls fn for the tokens stored in node and its children
+func walkTokens(node Node, fn func(Token)) {
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			walkTokens(s, fn)
+		}
+	case *LetStatement:
+		fn(n.Token)
+		walkTokens(n.Value, fn)
+	case *ReturnStatement:
+		fn(n.Token)
+		if n.ReturnValue != nil {
+			walkTokens(n.ReturnValue, fn)
+		}
+	case *ThrowStatement:
+		fn(n.Token)
+		walkTokens(n.Value, fn)
+	case *ExpressionStatement:
+		fn(n.Token)
+		walkTokens(n.Expression, fn)
+	case *FunctionStatement:
+		fn(n.Token)
+		for _, d := range n.Defaults {
+			if d != nil {
+				walkTokens(d, fn)
+			}
+		}
+		walkTokens(n.Body, fn)
+	case *IfStatement:
+		fn(n.Token)
+		walkTokens(n.Condition, fn)
+		walkTokens(n.Consequence, fn)
+		if n.Alternative != nil {
+			walkTokens(n.Alternative, fn)
+		}
+	case *TryStatement:
+		fn(n.Token)
+		walkTokens(n.Block, fn)
+		if n.Catch != nil {
+			walkTokens(n.Catch, fn)
+		}
+		if n.Finally != nil {
+			walkTokens(n.Finally, fn)
+		}
+	case *BlockStatement:
+		fn(n.Token)
+		for _, s := range n.Statements {
+			walkTokens(s, fn)
+		}
+		fn(n.RBrace)
+	case *Identifier:
+		fn(n.Token)
+	case *IntegerLiteral:
+		fn(n.Token)
+	case *StringLiteral:
+		fn(n.Token)
+	case *DecimalLiteral:
+		fn(n.Token)
+	case *BooleanLiteral:
+		fn(n.Token)
+	case *PrefixExpression:
+		fn(n.Token)
+		walkTokens(n.Right, fn)
+	case *InfixExpression:
+		walkTokens(n.Left, fn)
+		fn(n.Token)
+		walkTokens(n.Right, fn)
+	case *CallExpression:
+		walkTokens(n.Function, fn)
+		fn(n.Token)
+		for _, a := range n.Arguments {
+			walkTokens(a, fn)
+		}
+	case *NamedArgument:
+		fn(n.Token)
+		walkTokens(n.Value, fn)
+	case *IndexExpression:
+		walkTokens(n.Left, fn)
+		fn(n.Token)
+		walkTokens(n.Index, fn)
+	case *PropertyExpression:
+		walkTokens(n.Object, fn)
+		fn(n.Token)
+		walkTokens(n.Property, fn)
+	case *PropagateExpression:
+		walkTokens(n.Value, fn)
+		fn(n.Token)
+	case *SpawnExpression:
+		fn(n.Token)
+		walkTokens(n.Call, fn)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5;", "let x = 5;\n"},
+		{"let x = (1 + 2) * 3;", "let x = (1 + 2) * 3;\n"},
+		{"let x = 1 + (2 * 3);", "let x = 1 + 2 * 3;\n"},
+		{"let x = (1 - 2) - 3;", "let x = 1 - 2 - 3;\n"},
+		{"let x = 1 - (2 - 3);", "let x = 1 - (2 - 3);\n"},
+		{"let x = !(a && b) || (c && d);", "let x = !(a && b) || c && d;\n"},
+		{"let x = -(-5);", "let x = --5;\n"},
+		{"let x = (f)(1)[0]?;", "let x = f(1)[0]?;\n"},
+		{"func add(x,y){return x+y;}", "func add(x, y) {\n    return x + y;\n}\n"},
+		{`func greet(name, greeting="Hi", ...rest) { }`, "func greet(name, greeting = \"Hi\", ...rest) {}\n"},
+		{"if (x) { a; } else { b; }", "if (x) {\n    a;\n} else {\n    b;\n}\n"},
+		{"try { a; } catch (e) { b; } finally { c; }", "try {\n    a;\n} catch (e) {\n    b;\n} finally {\n    c;\n}\n"},
+		{"greet(name:\"Bob\"); throw xs[0+1];", "greet(name: \"Bob\");\nthrow xs[0 + 1];\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"let t = spawn work(1);", "let t = spawn work(1);\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Format(tt.input)
+		if err != nil {
+			t.Errorf("Format(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Format(%q) wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `// Header comment
+let x = 1; // trailing
+
+// Explains f
+func f(a) {
+    // inside
+    return a; // result
+    // before brace
+}
+if (x) { // opening
+    x;
+} else {
+    // only comment
+}
+// footer
+`
+
+	expected := `// Header comment
+let x = 1; // trailing
+
+// Explains f
+func f(a) {
+    // inside
+    return a; // result
+    // before brace
+}
+if (x) { // opening
+    x;
+} else {
+    // only comment
+}
+// footer
+`
+
+	got, err := Format(input)
+	if err != nil {
+		t.Fatalf("Format returned error: %v", err)
+	}
+	if got != expected {
+		t.Errorf("comments not preserved.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// TestFormatExamples checks that formatting every example preserves its
+// AST and that formatting is idempotent
+func TestFormatExamples(t *testing.T) {
+	files, err := filepath.Glob("examples/*.tiny")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example files found: %v", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+
+		formatted, err := Format(string(content))
+		if err != nil {
+			t.Fatalf("Format(%s) returned error: %v", file, err)
+		}
+
+		before := parseFile(t, file).String()
+		after := NewParser(New(formatted)).ParseProgram().String()
+		if before != after {
+			t.Errorf("%s: formatting changed the program.\nbefore=%s\nafter= %s", file, before, after)
+		}
+
+		again, err := Format(formatted)
+		if err != nil || again != formatted {
+			t.Errorf("%s: formatting is not idempotent", file)
+		}
+
+		if strings.Count(formatted, "//") != strings.Count(string(content), "//") {
+			t.Errorf("%s: comments were lost", file)
+		}
+	}
+}
+
+func TestFormatParseError(t *testing.T) {
+	if _, err := Format("let = 5;"); err == nil {
+		t.Errorf("expected error formatting invalid source")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if d := unifiedDiff("a.tiny", "same\n", "same\n"); d != "" {
+		t.Errorf("expected no diff for equal input, got %q", d)
+	}
+
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nK\n"
+	expected := `--- a.tiny.orig
++++ a.tiny
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,4 +8,4 @@
+ h
+ i
+ j
+-k
++K
+`
+	if d := unifiedDiff("a.tiny", before, after); d != expected {
+		t.Errorf("wrong diff.\nexpected:\n%s\ngot:\n%s", expected, d)
+	}
+}
+
+func TestRunFmt(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runFmt(nil, strings.NewReader("let x=1;"), &stdout, &stderr)
+	if code != 0 || stdout.String() != "let x = 1;\n" {
+		t.Errorf("fmt from stdin: code=%d stdout=%q stderr=%q", code, stdout.String(), stderr.String())
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prog.tiny")
+	if err := os.WriteFile(file, []byte("let x=1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout.Reset()
+	if code := runFmt([]string{"-d", file}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt -d failed: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "-let x=1;\n+let x = 1;\n") {
+		t.Errorf("fmt -d output wrong: %q", stdout.String())
+	}
+
+	if code := runFmt([]string{"-w", file}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt -w failed: %s", stderr.String())
+	}
+	content, _ := os.ReadFile(file)
+	if string(content) != "let x = 1;\n" {
+		t.Errorf("fmt -w wrote %q", content)
+	}
+
+	stderr.Reset()
+	if code := runFmt(nil, strings.NewReader("let = ;"), &stdout, &stderr); code != exitParseError || stderr.Len() == 0 {
+		t.Errorf("expected failure for invalid input, code=%d", code)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+// EvalHooks observes a running program. The evaluator calls the hooks of
+// a run with the node being evaluated, the token giving its position and
+// the environment it runs in. Hooks of programs that spawn tasks are
+// called from several goroutines at once.
+type EvalHooks interface {
+	// OnStatement is called before each statement runs. Returning a
+	// non-nil object aborts the program with that result.
+	OnStatement(stmt Statement, pos Token, env *Environment) Object
+
+	// OnCall is called before a function or builtin is applied to its
+	// positional arguments; env is the scope of the call site
+	OnCall(node Node, fn Object, args []Object, pos Token, env *Environment)
+
+	// OnReturn is called when a call made through OnCall ends, with the
+	// returned value or the error that ended it
+	OnReturn(node Node, fn Object, result Object, pos Token, env *Environment)
+
+	// OnError is called once for each error raised, with the innermost
+	// statement it escaped from, whether or not a try statement catches it
+	OnError(stmt Statement, err *Error, pos Token, env *Environment)
+}
+
+// BranchHook may be implemented by hooks that also want to know which
+// way each if statement went
+type BranchHook interface {
+	OnBranch(stmt *IfStatement, taken bool, env *Environment)
+}
+
+// BaseHooks implements EvalHooks by doing nothing. Embed it to implement
+// only the hooks of interest.
+type BaseHooks struct{}
+
+func (BaseHooks) OnStatement(stmt Statement, pos Token, env *Environment) Object            { return nil }
+func (BaseHooks) OnCall(node Node, fn Object, args []Object, pos Token, env *Environment)   {}
+func (BaseHooks) OnReturn(node Node, fn Object, result Object, pos Token, env *Environment) {}
+func (BaseHooks) OnError(stmt Statement, err *Error, pos Token, env *Environment)           {}
+
+// hookList runs several hooks in the order they were added
+type hookList []EvalHooks
+
+func (l hookList) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	for _, h := range l {
+		if stop := h.OnStatement(stmt, pos, env); stop != nil {
+			return stop
+		}
+	}
+	return nil
+}
+
+func (l hookList) OnCall(node Node, fn Object, args []Object, pos Token, env *Environment) {
+	for _, h := range l {
+		h.OnCall(node, fn, args, pos, env)
+	}
+}
+
+func (l hookList) OnReturn(node Node, fn Object, result Object, pos Token, env *Environment) {
+	for _, h := range l {
+		h.OnReturn(node, fn, result, pos, env)
+	}
+}
+
+func (l hookList) OnError(stmt Statement, err *Error, pos Token, env *Environment) {
+	for _, h := range l {
+		h.OnError(stmt, err, pos, env)
+	}
+}
+
+func (l hookList) OnBranch(stmt *IfStatement, taken bool, env *Environment) {
+	for _, h := range l {
+		if b, ok := h.(BranchHook); ok {
+			b.OnBranch(stmt, taken, env)
+		}
+	}
+}
+
+// AddHooks installs hooks on the run env belongs to, after any already
+// installed. It must be called before the program starts.
+func (e *Environment) AddHooks(h EvalHooks) {
+	switch existing := e.rt.hooks.(type) {
+	case nil:
+		e.rt.hooks = h
+	case hookList:
+		e.rt.hooks = append(existing, h)
+	default:
+		e.rt.hooks = hookList{existing, h}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingHooks logs every hook call
+type recordingHooks struct {
+	events []string
+}
+
+func (r *recordingHooks) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	r.events = append(r.events, fmt.Sprintf("stmt %d", pos.Line))
+	return nil
+}
+
+func (r *recordingHooks) OnCall(node Node, fn Object, args []Object, pos Token, env *Environment) {
+	r.events = append(r.events, fmt.Sprintf("call %s %d", traceName(fn), len(args)))
+}
+
+func (r *recordingHooks) OnReturn(node Node, fn Object, result Object, pos Token, env *Environment) {
+	r.events = append(r.events, fmt.Sprintf("return %s %s", traceName(fn), result.Inspect()))
+}
+
+func (r *recordingHooks) OnError(stmt Statement, err *Error, pos Token, env *Environment) {
+	r.events = append(r.events, fmt.Sprintf("error %d %s", pos.Line, err.Message))
+}
+
+func runWithHooks(input string, hooks ...EvalHooks) Object {
+	env := NewEnvironment()
+	for _, h := range hooks {
+		env.AddHooks(h)
+	}
+	return Eval(NewParser(New(input)).ParseProgram(), env)
+}
+
+func TestEvalHooks(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"func add(a, b) {\n    return a + b;\n}\nadd(1, 2);",
+			[]string{"stmt 1", "stmt 4", "call add 2", "stmt 2", "return add 3"},
+		},
+		{
+			"let x = len(\"a\");\nx;",
+			[]string{"stmt 1", "call len 1", "return len 1", "stmt 2"},
+		},
+		{
+			// An error is reported once, where it is raised
+			"func f() {\n    return 1 / 0;\n}\nf();",
+			[]string{"stmt 1", "stmt 4", "call f 0", "stmt 2", "error 2 division by zero", "return f ERROR: division by zero"},
+		},
+		{
+			// Caught errors are reported, and rethrowing raises them again
+			"try {\n    throw \"boom\";\n} catch (e) {\n    throw e;\n}",
+			[]string{"stmt 1", "stmt 2", "error 2 boom", "stmt 4", "error 4 boom"},
+		},
+		{
+			// Tail calls are reported like nested calls
+			"func g(n) {\n    return n;\n}\nfunc f(n) {\n    return g(n + 1);\n}\nf(1);",
+			[]string{"stmt 1", "stmt 4", "stmt 7", "call f 1", "stmt 5", "call g 1", "stmt 2", "return g 2", "return f 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		hooks := &recordingHooks{}
+		runWithHooks(tt.input, hooks)
+		if strings.Join(hooks.events, "; ") != strings.Join(tt.expected, "; ") {
+			t.Errorf("wrong events for %q.\nexpected=%v\ngot=     %v", tt.input, tt.expected, hooks.events)
+		}
+	}
+}
+
+// abortHooks stops the program at a line
+type abortHooks struct {
+	BaseHooks
+	line int
+}
+
+func (a abortHooks) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	if pos.Line == a.line {
+		return newError("stopped at line %d", pos.Line)
+	}
+	return nil
+}
+
+func TestEvalHooksAbort(t *testing.T) {
+	recorder := &recordingHooks{}
+	result := runWithHooks("let a = 1;\nlet b = 2;\nlet c = 3;", abortHooks{line: 2}, recorder)
+
+	if err, ok := result.(*Error); !ok || err.Message != "stopped at line 2" {
+		t.Fatalf("expected abort error, got %s", result.Inspect())
+	}
+	// Later hooks do not see the aborted statement, and aborting is not
+	// reported as an error of the program
+	if strings.Join(recorder.events, "; ") != "stmt 1" {
+		t.Errorf("wrong events: %v", recorder.events)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	input := `func add(a, b) {
+    return a + b;
+}
+func twice(x) {
+    return add(x, x);
+}
+twice(add(1, 2));
+add(1, "a");`
+
+	var out bytes.Buffer
+	runWithHooks(input, NewTracer(&out))
+
+	expected := `-> add(1, 2) (line 7)
+<- add: 3
+-> twice(3) (line 7)
+  -> add(3, 3) (line 5)
+  <- add: 6
+<- twice: 6
+-> add(1, a) (line 8)
+<- add: ERROR: type mismatch: INTEGER + STRING
+`
+	if out.String() != expected {
+		t.Errorf("wrong trace.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
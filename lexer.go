@@ -1,5 +1,7 @@
 package main
 
+import "strings"
+
 // Lexer represents the lexical analyzer
 type Lexer struct {
 	input        string
@@ -8,6 +10,24 @@ type Lexer struct {
 	ch           byte
 	line         int
 	column       int
+
+	// comments collects the comments skipped so far, and lastLine is
+	// the line of the most recent token, used to tell trailing comments
+	// from those on a line of their own
+	comments []Comment
+	lastLine int
+
+	// trivia makes the lexer emit COMMENT tokens and record whitespace
+	// on each token instead of discarding it
+	trivia bool
+}
+
+// Comment is a single-line comment found in the source
+type Comment struct {
+	Text     string
+	Line     int
+	Column   int
+	Trailing bool
 }
 
 // New creates a new lexer instance
@@ -21,6 +41,16 @@ func New(input string) *Lexer {
 	return l
 }
 
+// NewWithTrivia creates a lexer in trivia mode: comments are returned as
+// COMMENT tokens and every token carries its surrounding whitespace, so
+// concatenating Leading, Source() and Trailing of all tokens reproduces
+// the input
+func NewWithTrivia(input string) *Lexer {
+	l := New(input)
+	l.trivia = true
+	return l
+}
+
 // readChar reads the next character and advances the position
 func (l *Lexer) readChar() {
 	if l.readPosition >= len(l.input) {
@@ -50,6 +80,40 @@ func (l *Lexer) peekChar() byte {
 
 // NextToken scans the input and returns the next token
 func (l *Lexer) NextToken() Token {
+	if !l.trivia {
+		return l.scanToken()
+	}
+
+	start := l.offset()
+	l.skipWhitespace()
+	leading := l.input[start:l.offset()]
+
+	tok := l.scanToken()
+	tok.Leading = leading
+	if tok.Type != EOF {
+		start = l.offset()
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+			l.readChar()
+		}
+		if l.ch == '\n' {
+			l.readChar()
+		}
+		tok.Trailing = l.input[start:l.offset()]
+	}
+	return tok
+}
+
+// offset returns the byte offset of the current character, clamped to the
+// end of the input
+func (l *Lexer) offset() int {
+	if l.position > len(l.input) {
+		return len(l.input)
+	}
+	return l.position
+}
+
+// scanToken skips whitespace and scans the next token
+func (l *Lexer) scanToken() Token {
 	var tok Token
 
 	// Skip whitespace (except newlines, which we track for line numbers)
@@ -77,8 +141,11 @@ func (l *Lexer) NextToken() Token {
 	case '/':
 		// Check for single-line comments
 		if l.peekChar() == '/' {
-			l.skipComment()
-			return l.NextToken()
+			text := l.skipComment(line, column)
+			if l.trivia {
+				return Token{Type: COMMENT, Literal: text, Line: line, Column: column}
+			}
+			return l.scanToken()
 		}
 		tok = newToken(DIVIDE, l.ch, line, column)
 	case '!':
@@ -121,8 +188,20 @@ func (l *Lexer) NextToken() Token {
 		} else {
 			tok = newToken(ILLEGAL, l.ch, line, column)
 		}
+	case '?':
+		tok = newToken(QUESTION, l.ch, line, column)
 	case ',':
 		tok = newToken(COMMA, l.ch, line, column)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = Token{Type: ELLIPSIS, Literal: "...", Line: line, Column: column}
+		} else {
+			tok = newToken(DOT, l.ch, line, column)
+		}
+	case ':':
+		tok = newToken(COLON, l.ch, line, column)
 	case ';':
 		tok = newToken(SEMICOLON, l.ch, line, column)
 	case '(':
@@ -133,6 +212,10 @@ func (l *Lexer) NextToken() Token {
 		tok = newToken(LBRACE, l.ch, line, column)
 	case '}':
 		tok = newToken(RBRACE, l.ch, line, column)
+	case '[':
+		tok = newToken(LBRACKET, l.ch, line, column)
+	case ']':
+		tok = newToken(RBRACKET, l.ch, line, column)
 	case '"':
 		tok.Type = STRING
 		tok.Literal = l.readString()
@@ -149,12 +232,13 @@ func (l *Lexer) NextToken() Token {
 			tok.Column = column
 			tok.Literal = l.readIdentifier()
 			tok.Type = LookupIdent(tok.Literal)
+			l.lastLine = line
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
 			tok.Line = line
 			tok.Column = column
+			l.lastLine = line
 			return tok
 		} else {
 			tok = newToken(ILLEGAL, l.ch, line, column)
@@ -162,9 +246,15 @@ func (l *Lexer) NextToken() Token {
 	}
 
 	l.readChar()
+	l.lastLine = line
 	return tok
 }
 
+// Comments returns the comments skipped by the lexer so far, in source order
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
 // newToken creates a new token with the given type and character
 func newToken(tokenType TokenType, ch byte, line, column int) Token {
 	return Token{Type: tokenType, Literal: string(ch), Line: line, Column: column}
@@ -179,13 +269,28 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// readNumber reads a number
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a decimal such as 12.50d. A number with
+// a fraction but no d suffix is ILLEGAL, as there are no floats.
+func (l *Lexer) readNumber() (TokenType, string) {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	fraction := l.ch == '.' && isDigit(l.peekChar())
+	if fraction {
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+	if l.ch == 'd' {
+		l.readChar()
+		return DECIMAL, l.input[position:l.position]
+	}
+	if fraction {
+		return ILLEGAL, l.input[position:l.position]
+	}
+	return INT, l.input[position:l.position]
 }
 
 // readString reads a string literal
@@ -207,11 +312,22 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// skipComment skips single-line comments (// comment)
-func (l *Lexer) skipComment() {
+// skipComment skips single-line comments (// comment), recording them
+// so that tools like the formatter can put them back. It returns the raw
+// comment text.
+func (l *Lexer) skipComment(line, column int) string {
+	position := l.position
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	text := l.input[position:l.offset()]
+	l.comments = append(l.comments, Comment{
+		Text:     strings.TrimRight(text, " \t\r"),
+		Line:     line,
+		Column:   column,
+		Trailing: l.lastLine == line,
+	})
+	return text
 }
 
 // isLetter checks if a character is a letter or underscore
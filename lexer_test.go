@@ -1,6 +1,9 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -227,6 +230,22 @@ func TestStringLiterals(t *testing.T) {
 	}
 }
 
+func TestDelimiters(t *testing.T) {
+	input := `f(...xs, a: [1]).b`
+
+	expected := []TokenType{IDENT, LPAREN, ELLIPSIS, IDENT, COMMA, IDENT, COLON,
+		LBRACKET, INT, RBRACKET, RPAREN, DOT, IDENT, EOF}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q (%q)",
+				i, want, tok.Type, tok.Literal)
+		}
+	}
+}
+
 func TestOperators(t *testing.T) {
 	input := `= + - * / == != < > && || !`
 
@@ -244,3 +263,67 @@ func TestOperators(t *testing.T) {
 		}
 	}
 }
+
+func TestTriviaMode(t *testing.T) {
+	input := "let x = 5;  // five\n\n// note\n\tlet y = \"s\";\n"
+
+	expected := []struct {
+		typ      TokenType
+		literal  string
+		leading  string
+		trailing string
+	}{
+		{LET, "let", "", " "},
+		{IDENT, "x", "", " "},
+		{ASSIGN, "=", "", " "},
+		{INT, "5", "", ""},
+		{SEMICOLON, ";", "", "  "},
+		{COMMENT, "// five", "", "\n"},
+		{COMMENT, "// note", "\n", "\n"},
+		{LET, "let", "\t", " "},
+		{IDENT, "y", "", " "},
+		{ASSIGN, "=", "", " "},
+		{STRING, "s", "", ""},
+		{SEMICOLON, ";", "", "\n"},
+		{EOF, "", "", ""},
+	}
+
+	l := NewWithTrivia(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want.typ || tok.Literal != want.literal ||
+			tok.Leading != want.leading || tok.Trailing != want.trailing {
+			t.Errorf("Token %d: expected %s %q (leading %q, trailing %q), got %s %q (leading %q, trailing %q)",
+				i, want.typ, want.literal, want.leading, want.trailing,
+				tok.Type, tok.Literal, tok.Leading, tok.Trailing)
+		}
+	}
+
+	if len(l.Comments()) != 2 {
+		t.Errorf("Expected 2 recorded comments, got %d", len(l.Comments()))
+	}
+}
+
+// TestTriviaRoundTrip checks that trivia mode loses nothing from the
+// example programs
+func TestTriviaRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("examples/*.tiny")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example files found: %v", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+
+		var out strings.Builder
+		for _, tok := range NewWithTrivia(string(content)).TokenizeAll() {
+			out.WriteString(tok.Leading + tok.Source() + tok.Trailing)
+		}
+		if out.String() != string(content) {
+			t.Errorf("%s: trivia round trip lost input", file)
+		}
+	}
+}
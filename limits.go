@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits stops a program that runs too many statements, calls too deeply
+// or runs for too long, with a LimitError that try cannot catch. A zero
+// field means no limit. The timeout starts with the first statement.
+type Limits struct {
+	BaseHooks
+
+	MaxSteps int64
+	MaxDepth int
+	Timeout  time.Duration
+
+	steps    atomic.Int64
+	start    sync.Once
+	deadline time.Time
+}
+
+// OnStatement implements EvalHooks
+func (l *Limits) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	l.start.Do(func() {
+		if l.Timeout > 0 {
+			l.deadline = time.Now().Add(l.Timeout)
+		}
+	})
+
+	if l.MaxSteps > 0 && l.steps.Add(1) > l.MaxSteps {
+		return positionError(newErrorKind(LIMIT_ERROR, "step limit of %d exceeded", l.MaxSteps), pos)
+	}
+	if l.MaxDepth > 0 && env.frame != nil && env.frame.Depth > l.MaxDepth {
+		return positionError(newErrorKind(LIMIT_ERROR, "call depth limit of %d exceeded", l.MaxDepth), pos)
+	}
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return positionError(newErrorKind(LIMIT_ERROR, "time limit of %s exceeded", l.Timeout), pos)
+	}
+	return nil
+}
+
+// hooks returns the limits as a hook, or nil if no limit is set
+func (l *Limits) hooks() []EvalHooks {
+	if l.MaxSteps == 0 && l.MaxDepth == 0 && l.Timeout == 0 {
+		return nil
+	}
+	return []EvalHooks{l}
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lint rule names, as used in .tinylint files and lint:ignore comments
+const (
+	RuleUnusedVariable     = "unused-variable"
+	RuleUnusedParameter    = "unused-parameter"
+	RuleShadowing          = "shadowing"
+	RuleUnreachable        = "unreachable"
+	RuleConstantCondition  = "constant-condition"
+	RuleInconsistentReturn = "inconsistent-return"
+	RuleUndefined          = "undefined"
+	RuleArity              = "arity"
+	RuleDuplicateFunction  = "duplicate-function"
+	RuleReturnOutside      = "return-outside-function"
+)
+
+// lintRules lists every rule the linter knows about
+var lintRules = []string{
+	RuleUnusedVariable,
+	RuleUnusedParameter,
+	RuleShadowing,
+	RuleUnreachable,
+	RuleConstantCondition,
+	RuleInconsistentReturn,
+	RuleUndefined,
+	RuleArity,
+	RuleDuplicateFunction,
+	RuleReturnOutside,
+}
+
+// lintConfigFile is the name of the per-project lint configuration
+const lintConfigFile = ".tinylint"
+
+// Diagnostic is a problem found in a program by static analysis
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+// LintConfig selects which rules are reported
+type LintConfig struct {
+	Rules map[string]bool
+}
+
+// DefaultLintConfig enables every rule
+func DefaultLintConfig() LintConfig {
+	config := LintConfig{Rules: make(map[string]bool, len(lintRules))}
+	for _, rule := range lintRules {
+		config.Rules[rule] = true
+	}
+	return config
+}
+
+// Enabled reports whether rule is switched on
+func (c LintConfig) Enabled(rule string) bool {
+	return c.Rules[rule]
+}
+
+// ParseLintConfig reads a .tinylint file. Each line has the form
+// "rule = on" or "rule = off"; blank lines and lines starting with # are
+// ignored. Rules not mentioned stay enabled.
+func ParseLintConfig(text string) (LintConfig, error) {
+	config := DefaultLintConfig()
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return config, fmt.Errorf("line %d: expected rule = on|off", i+1)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		if _, known := config.Rules[name]; !known {
+			return config, fmt.Errorf("line %d: unknown rule %q", i+1, name)
+		}
+		switch value {
+		case "on":
+			config.Rules[name] = true
+		case "off":
+			config.Rules[name] = false
+		default:
+			return config, fmt.Errorf("line %d: rule %s must be on or off, got %q", i+1, name, value)
+		}
+	}
+
+	return config, nil
+}
+
+// findLintConfig looks for a .tinylint file in dir and its parents and
+// loads the first one found, falling back to the default configuration
+func findLintConfig(dir string) (LintConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return DefaultLintConfig(), err
+	}
+
+	for {
+		content, err := os.ReadFile(filepath.Join(dir, lintConfigFile))
+		if err == nil {
+			config, err := ParseLintConfig(string(content))
+			if err != nil {
+				return config, fmt.Errorf("%s: %v", filepath.Join(dir, lintConfigFile), err)
+			}
+			return config, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return DefaultLintConfig(), nil
+		}
+		dir = parent
+	}
+}
+
+// LintSource parses and lints a program, returning parse errors as an error
+func LintSource(source string, config LintConfig) ([]Diagnostic, error) {
+	l := New(source)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("parse errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+	return Lint(program, l.Comments(), config), nil
+}
+
+// Lint checks a parsed program against the enabled rules. comments are
+// the lexer's comments, used for lint:ignore directives.
+func Lint(program *Program, comments []Comment, config LintConfig) []Diagnostic {
+	return analyze(program, comments, config).diagnostics
+}
+
+// analyze runs the linter over a program and returns it, so callers can
+// use the name resolution as well as the diagnostics
+func analyze(program *Program, comments []Comment, config LintConfig) *linter {
+	l := &linter{
+		config:  config,
+		ignored: lintIgnores(comments),
+		refs:    map[*Identifier]*lintBinding{},
+	}
+
+	l.push(nil)
+	l.declareAll(program.Statements)
+	l.statements(program.Statements)
+	l.pop()
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l
+}
+
+// lintIgnores maps source lines to the rules suppressed on them. A
+// "// lint:ignore rule" comment at the end of a line covers that line; on
+// a line of its own it covers the next line.
+func lintIgnores(comments []Comment) map[int][]string {
+	ignored := map[int][]string{}
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(text, "lint:ignore") {
+			continue
+		}
+
+		line := c.Line
+		if !c.Trailing {
+			line++
+		}
+		rules := strings.FieldsFunc(strings.TrimPrefix(text, "lint:ignore"), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		ignored[line] = append(ignored[line], rules...)
+	}
+	return ignored
+}
+
+// bindingKind says how a name was introduced
+type bindingKind int
+
+const (
+	letBinding bindingKind = iota
+	parameterBinding
+	functionBinding
+	catchBinding
+)
+
+// lintBinding is one declared name
+type lintBinding struct {
+	kind  bindingKind
+	name  *Identifier
+	fn    *FunctionStatement
+	scope *lintScope
+	used  bool
+	uses  []*Identifier
+}
+
+// lintScope mirrors a runtime environment: the program or one function
+// call. Blocks do not open scopes, as in the evaluator.
+type lintScope struct {
+	outer    *lintScope
+	fn       *FunctionStatement // nil for the program
+	names    map[string]*lintBinding
+	bindings []*lintBinding
+
+	// declared holds every name declared anywhere in the scope, and
+	// early the ones nested functions read before their declaration;
+	// such reads are fine because function bodies run later
+	declared map[string]bool
+	early    map[string][]*Identifier
+
+	returnsValue bool
+	returnsBare  bool
+}
+
+// linter walks the AST keeping track of scopes
+type linter struct {
+	config      LintConfig
+	ignored     map[int][]string
+	scope       *lintScope
+	diagnostics []Diagnostic
+
+	// scopes and refs record the name resolution: every scope opened and
+	// the binding each declared or resolved identifier refers to
+	scopes []*lintScope
+	refs   map[*Identifier]*lintBinding
+}
+
+// report records a diagnostic unless its rule is disabled or ignored
+func (l *linter) report(tok Token, rule, format string, args ...interface{}) {
+	if !l.config.Enabled(rule) {
+		return
+	}
+	for _, ignored := range l.ignored[tok.Line] {
+		if ignored == rule || ignored == "all" {
+			return
+		}
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// push opens a new scope for fn, or for the program when fn is nil
+func (l *linter) push(fn *FunctionStatement) {
+	l.scope = &lintScope{
+		outer:    l.scope,
+		fn:       fn,
+		names:    map[string]*lintBinding{},
+		declared: map[string]bool{},
+		early:    map[string][]*Identifier{},
+	}
+	l.scopes = append(l.scopes, l.scope)
+}
+
+// pop closes the current scope, reporting bindings that were never read
+func (l *linter) pop() {
+	for _, b := range l.scope.bindings {
+		if b.used || strings.HasPrefix(b.name.Value, "_") {
+			continue
+		}
+		switch b.kind {
+		case letBinding:
+			l.report(b.name.Token, RuleUnusedVariable, "%s declared and not used", b.name.Value)
+		case parameterBinding:
+			l.report(b.name.Token, RuleUnusedParameter, "parameter %s is not used", b.name.Value)
+		}
+	}
+	l.scope = l.scope.outer
+}
+
+// declareAll records the names a scope declares and hoists its function
+// declarations, so calls may appear before them in the source. A function
+// declared twice in the same statement list is reported.
+func (l *linter) declareAll(stmts []Statement) {
+	functions := map[string]*FunctionStatement{}
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *LetStatement:
+			l.scope.declared[s.Name.Value] = true
+		case *FunctionStatement:
+			if first, ok := functions[s.Name.Value]; ok {
+				l.report(s.Name.Token, RuleDuplicateFunction, "%s already declared on line %d", s.Name.Value, first.Token.Line)
+			}
+			functions[s.Name.Value] = s
+			l.scope.declared[s.Name.Value] = true
+			l.bind(&lintBinding{kind: functionBinding, name: s.Name, fn: s})
+		case *IfStatement:
+			l.declareAll(s.Consequence.Statements)
+			if s.Alternative != nil {
+				l.declareAll(s.Alternative.Statements)
+			}
+		case *TryStatement:
+			l.declareAll(s.Block.Statements)
+			if s.Catch != nil {
+				l.scope.declared[s.CatchParam.Value] = true
+				l.declareAll(s.Catch.Statements)
+			}
+			if s.Finally != nil {
+				l.declareAll(s.Finally.Statements)
+			}
+		case *BlockStatement:
+			l.declareAll(s.Statements)
+		}
+	}
+}
+
+// declare binds a name in the current scope, warning when it hides a
+// name from an enclosing one
+func (l *linter) declare(kind bindingKind, name *Identifier) {
+	for s := l.scope.outer; s != nil; s = s.outer {
+		if _, ok := s.names[name.Value]; ok || s.declared[name.Value] {
+			l.report(name.Token, RuleShadowing, "%s shadows a declaration in an outer scope", name.Value)
+			break
+		}
+	}
+	b := &lintBinding{kind: kind, name: name}
+	for _, use := range l.scope.early[name.Value] {
+		b.used = true
+		b.uses = append(b.uses, use)
+		l.refs[use] = b
+	}
+	delete(l.scope.early, name.Value)
+	l.bind(b)
+}
+
+// bind adds a binding to the current scope
+func (l *linter) bind(b *lintBinding) {
+	b.scope = l.scope
+	l.scope.names[b.name.Value] = b
+	l.scope.bindings = append(l.scope.bindings, b)
+	l.refs[b.name] = b
+}
+
+// runGlobals are the names runFile binds before a program starts, which
+// are declared like builtins
+var runGlobals = map[string]bool{"args": true}
+
+// resolve marks the binding an identifier refers to as used and returns
+// it, or reports the identifier when nothing declares it
+func (l *linter) resolve(id *Identifier) *lintBinding {
+	for s := l.scope; s != nil; s = s.outer {
+		if b, ok := s.names[id.Value]; ok {
+			b.used = true
+			b.uses = append(b.uses, id)
+			l.refs[id] = b
+			return b
+		}
+		if s != l.scope && s.declared[id.Value] {
+			s.early[id.Value] = append(s.early[id.Value], id)
+			return nil
+		}
+	}
+	if _, ok := builtins[id.Value]; ok || runGlobals[id.Value] {
+		return nil
+	}
+	l.report(id.Token, RuleUndefined, "undefined: %s", id.Value)
+	return nil
+}
+
+// statements lints a statement list and reports whether it always ends
+// in a return or throw
+func (l *linter) statements(stmts []Statement) bool {
+	for i, stmt := range stmts {
+		if l.statement(stmt) {
+			if i+1 < len(stmts) {
+				l.report(statementToken(stmts[i+1]), RuleUnreachable, "unreachable code")
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// statement lints one statement and reports whether it always ends in a
+// return or throw
+func (l *linter) statement(stmt Statement) bool {
+	switch s := stmt.(type) {
+	case *LetStatement:
+		l.expression(s.Value)
+		l.declare(letBinding, s.Name)
+	case *FunctionStatement:
+		l.function(s)
+	case *ReturnStatement:
+		if l.scope.fn == nil {
+			l.report(s.Token, RuleReturnOutside, "return outside function")
+		}
+		if s.ReturnValue != nil {
+			l.expression(s.ReturnValue)
+			l.scope.returnsValue = true
+		} else {
+			l.scope.returnsBare = true
+		}
+		return true
+	case *ThrowStatement:
+		l.expression(s.Value)
+		return true
+	case *ExpressionStatement:
+		l.expression(s.Expression)
+	case *IfStatement:
+		l.condition(s)
+		l.expression(s.Condition)
+		consequence := l.statements(s.Consequence.Statements)
+		alternative := s.Alternative != nil && l.statements(s.Alternative.Statements)
+		return consequence && alternative
+	case *TryStatement:
+		block := l.statements(s.Block.Statements)
+		handled := true
+		if s.Catch != nil {
+			l.bind(&lintBinding{kind: catchBinding, name: s.CatchParam})
+			handled = l.statements(s.Catch.Statements)
+		}
+		finally := s.Finally != nil && l.statements(s.Finally.Statements)
+		return finally || block && handled
+	case *BlockStatement:
+		return l.statements(s.Statements)
+	}
+	return false
+}
+
+// function lints a function declaration in a scope of its own
+func (l *linter) function(fs *FunctionStatement) {
+	l.push(fs)
+	l.declareAll(fs.Body.Statements)
+
+	for i, param := range fs.Parameters {
+		if i < len(fs.Defaults) && fs.Defaults[i] != nil {
+			l.expression(fs.Defaults[i])
+		}
+		l.declare(parameterBinding, param)
+	}
+	if fs.Rest != nil {
+		l.declare(parameterBinding, fs.Rest)
+	}
+
+	terminates := l.statements(fs.Body.Statements)
+	if l.scope.returnsValue && (!terminates || l.scope.returnsBare) {
+		l.report(fs.Name.Token, RuleInconsistentReturn,
+			"%s returns a value on some paths but not on others", fs.Name.Value)
+	}
+
+	l.pop()
+}
+
+// condition warns about if conditions built only from literals
+func (l *linter) condition(s *IfStatement) {
+	if !isConstantExpression(s.Condition) {
+		return
+	}
+	value := Eval(s.Condition, NewEnvironment())
+	if isError(value) {
+		l.report(s.Token, RuleConstantCondition, "condition is constant")
+		return
+	}
+	l.report(s.Token, RuleConstantCondition, "condition is always %t", isTruthy(value))
+}
+
+// isConstantExpression reports whether exp involves no names or calls
+func isConstantExpression(exp Expression) bool {
+	switch e := exp.(type) {
+	case *IntegerLiteral, *StringLiteral, *DecimalLiteral, *BooleanLiteral:
+		return true
+	case *PrefixExpression:
+		return isConstantExpression(e.Right)
+	case *InfixExpression:
+		return isConstantExpression(e.Left) && isConstantExpression(e.Right)
+	default:
+		return false
+	}
+}
+
+// expression lints the identifiers and calls inside an expression
+func (l *linter) expression(exp Expression) {
+	switch e := exp.(type) {
+	case *Identifier:
+		l.resolve(e)
+	case *PrefixExpression:
+		l.expression(e.Right)
+	case *InfixExpression:
+		l.expression(e.Left)
+		l.expression(e.Right)
+	case *CallExpression:
+		var callee *lintBinding
+		id, ok := e.Function.(*Identifier)
+		if ok {
+			callee = l.resolve(id)
+		} else {
+			l.expression(e.Function)
+		}
+		for _, arg := range e.Arguments {
+			l.expression(arg)
+		}
+		if callee != nil && callee.kind == functionBinding {
+			l.arity(id.Token, e, callee.fn)
+		}
+	case *NamedArgument:
+		l.expression(e.Value)
+	case *IndexExpression:
+		l.expression(e.Left)
+		l.expression(e.Index)
+	case *PropertyExpression:
+		l.expression(e.Object)
+	case *PropagateExpression:
+		l.expression(e.Value)
+	case *SpawnExpression:
+		l.expression(e.Call)
+	}
+}
+
+// arity checks a call's arguments against the declaration it calls,
+// using the same rules and messages as the evaluator. Problems are
+// reported at tok, the callee's name.
+func (l *linter) arity(tok Token, call *CallExpression, fs *FunctionStatement) {
+	fn := &Function{Name: fs.Name.Value, Parameters: fs.Parameters, Defaults: fs.Defaults, Rest: fs.Rest}
+
+	positional := 0
+	bound := map[string]bool{}
+	var named []*NamedArgument
+	for _, arg := range call.Arguments {
+		if n, ok := arg.(*NamedArgument); ok {
+			named = append(named, n)
+			continue
+		}
+		if positional < len(fs.Parameters) {
+			bound[fs.Parameters[positional].Value] = true
+		}
+		positional++
+	}
+
+	if positional > len(fs.Parameters) && fs.Rest == nil {
+		l.report(tok, RuleArity, "%s", newArityError(fn, positional+len(named)).Message)
+		return
+	}
+
+	for _, arg := range named {
+		if !hasParameter(fn, arg.Name.Value) {
+			l.report(arg.Name.Token, RuleArity, "unknown argument %s in call to %s", arg.Name.Value, fn.Name)
+			return
+		}
+		if bound[arg.Name.Value] {
+			l.report(arg.Name.Token, RuleArity, "argument %s given more than once in call to %s", arg.Name.Value, fn.Name)
+			return
+		}
+		bound[arg.Name.Value] = true
+	}
+
+	for i, param := range fs.Parameters {
+		if bound[param.Value] || i < len(fs.Defaults) && fs.Defaults[i] != nil {
+			continue
+		}
+		if len(named) == 0 {
+			l.report(tok, RuleArity, "%s", newArityError(fn, positional).Message)
+		} else {
+			l.report(tok, RuleArity, "missing argument %s in call to %s", param.Value, fn.Name)
+		}
+		return
+	}
+}
+
+// hasParameter reports whether fn declares a positional parameter name
+func hasParameter(fn *Function, name string) bool {
+	for _, param := range fn.Parameters {
+		if param.Value == name {
+			return true
+		}
+	}
+	return false
+}
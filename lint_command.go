@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runLint implements "tinylang lint [-config file] [paths...]". Directories
+// are searched for .tiny files; without paths the current directory is
+// linted. It returns 1 when any problem is found.
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", "", "use this config file instead of the nearest "+lintConfigFile)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tinylang lint [-config file] [file.tiny | dir ...]")
+		flags.PrintDefaults()
+		fmt.Fprintf(stderr, "Rules: %s\n", strings.Join(lintRules, ", "))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var config *LintConfig
+	if *configPath != "" {
+		content, err := os.ReadFile(*configPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "lint: %v\n", err)
+			return exitUsage
+		}
+		parsed, err := ParseLintConfig(string(content))
+		if err != nil {
+			fmt.Fprintf(stderr, "lint: %s: %v\n", *configPath, err)
+			return exitUsage
+		}
+		config = &parsed
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := tinyFiles(paths)
+	if err != nil {
+		fmt.Fprintf(stderr, "lint: %v\n", err)
+		return exitUsage
+	}
+
+	status := exitOK
+	for _, filename := range files {
+		fileConfig := config
+		if fileConfig == nil {
+			found, err := findLintConfig(filepath.Dir(filename))
+			if err != nil {
+				fmt.Fprintf(stderr, "lint: %v\n", err)
+				return exitUsage
+			}
+			fileConfig = &found
+		}
+
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(stderr, "lint: %v\n", err)
+			status = exitUsage
+			continue
+		}
+
+		diagnostics, err := LintSource(string(source), *fileConfig)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", filename, err)
+			status = exitParseError
+			continue
+		}
+		for _, d := range diagnostics {
+			fmt.Fprintf(stdout, "%s:%s\n", filename, d)
+			if status == exitOK {
+				status = exitRuntimeError
+			}
+		}
+	}
+	return status
+}
+
+// tinyFiles expands paths into .tiny files, walking directories
+func tinyFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, ".tiny") && !strings.HasSuffix(p, "_test.tiny") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1;", []string{"1:5: x declared and not used (unused-variable)"}},
+		{"let _x = 1;", nil},
+		{"func f(a, b) { return a; } f(1, 2);", []string{"1:11: parameter b is not used (unused-parameter)"}},
+		{"func f(...rest) { return 1; } f();", []string{"1:11: parameter rest is not used (unused-parameter)"}},
+		{"let x = 1; func f(x) { return x; } f(x);", []string{"1:19: x shadows a declaration in an outer scope (shadowing)"}},
+		{"func f() { let y = 1; return y; } let y = f(); print(y);", []string{"1:16: y shadows a declaration in an outer scope (shadowing)"}},
+		{"func f() { return 1; print(2); } f();", []string{"1:22: unreachable code (unreachable)"}},
+		{"func f(a) { if (a) { return 1; } else { throw \"no\"; } a; } f(1);", []string{"1:55: unreachable code (unreachable)"}},
+		{"if (true) { print(1); }", []string{"1:1: condition is always true (constant-condition)"}},
+		{"if (1 > 2) { print(1); }", []string{"1:1: condition is always false (constant-condition)"}},
+		{"if (1 / 0) { print(1); }", []string{"1:1: condition is constant (constant-condition)"}},
+		{"func f(a) { if (a) { return 1; } } f(1);", []string{"1:6: f returns a value on some paths but not on others (inconsistent-return)"}},
+		{"func f(a) { if (a) { return 1; } return; } f(1);", []string{"1:6: f returns a value on some paths but not on others (inconsistent-return)"}},
+		{"func f(a) { if (a) { return 1; } return 2; } f(1);", nil},
+		{"func f(a) { try { return a; } catch (e) { return 0; } } f(1);", nil},
+		{"func f(a) { if (a) { print(a); } } f(1);", nil},
+		{"print(y);", []string{"1:7: undefined: y (undefined)"}},
+		{"missing(1);", []string{"1:1: undefined: missing (undefined)"}},
+		{"func f() { return later; } let later = 1; f();", nil},
+		{"let a = b(); func b() { return 1; } print(a);", nil},
+		{"try { throw 1; } catch (e) { print(e); }", nil},
+		{"func add(a, b) { return a + b; } add(1);", []string{"1:34: wrong number of arguments to add: got=1, want=2 (arity)"}},
+		{"func add(a, b) { return a + b; } add(1, 2, 3);", []string{"1:34: wrong number of arguments to add: got=3, want=2 (arity)"}},
+		{"func g(a, b = 2) { return a + b; } g(1); g(1, 2); g(b: 1, a: 2);", nil},
+		{"func g(a, b = 2) { return a + b; } g(c: 1);", []string{"1:38: unknown argument c in call to g (arity)"}},
+		{"func g(a, b = 2) { return a + b; } g(b: 1);", []string{"1:36: missing argument a in call to g (arity)"}},
+		{"func g(a, ...r) { return r[a]; } g(1, 2, 3);", nil},
+		{"let g = len; g(1, 2);", nil},
+		{"func f() { return 1; }\nfunc f() { return 2; } f();", []string{"2:6: f already declared on line 1 (duplicate-function)"}},
+		{"func f(a) { if (a) { func g() { return 1; } return g(); } func g() { return 2; } return g(); } f(1);", nil},
+		{"print(1); return;", []string{"1:11: return outside function (return-outside-function)"}},
+		{"if (1 > len(\"a\")) { return 2; }", []string{"1:21: return outside function (return-outside-function)"}},
+	}
+
+	for _, tt := range tests {
+		diagnostics, err := LintSource(tt.input, DefaultLintConfig())
+		if err != nil {
+			t.Fatalf("LintSource(%q) returned error: %v", tt.input, err)
+		}
+
+		var got []string
+		for _, d := range diagnostics {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("lint %q wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLintIgnore(t *testing.T) {
+	input := `let a = 1; // lint:ignore unused-variable
+// lint:ignore undefined, unused-variable
+let b = c;
+let d = 1; // lint:ignore shadowing
+// lint:ignore all
+let e = f;`
+
+	diagnostics, err := LintSource(input, DefaultLintConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].String() != "4:5: d declared and not used (unused-variable)" {
+		t.Errorf("wrong diagnostics: %v", diagnostics)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config, err := ParseLintConfig("# quieter\nunused-variable = off\n\nshadowing=on\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Enabled(RuleUnusedVariable) || !config.Enabled(RuleShadowing) || !config.Enabled(RuleArity) {
+		t.Errorf("wrong config: %v", config.Rules)
+	}
+
+	diagnostics, _ := LintSource("let x = 1;", config)
+	if len(diagnostics) != 0 {
+		t.Errorf("disabled rule still reported: %v", diagnostics)
+	}
+
+	for _, input := range []string{"unused = off", "arity off", "arity = maybe"} {
+		if _, err := ParseLintConfig(input); err == nil {
+			t.Errorf("expected error for config %q", input)
+		}
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "src")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(sub, "prog.tiny")
+	os.WriteFile(file, []byte("let x = 1;\nprint(y);\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := runLint([]string{dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d (%s)", code, stderr.String())
+	}
+	expected := file + ":1:5: x declared and not used (unused-variable)\n" +
+		file + ":2:7: undefined: y (undefined)\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, stdout.String())
+	}
+
+	// A .tinylint in a parent directory applies to the files below it
+	os.WriteFile(filepath.Join(dir, ".tinylint"), []byte("unused-variable = off\nundefined = off\n"), 0644)
+	stdout.Reset()
+	if code := runLint([]string{file}, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("expected clean run, got code %d: %s", code, stdout.String())
+	}
+
+	// A file that does not parse is a parse error, not a lint problem
+	os.WriteFile(file, []byte("let = 1;\n"), 0644)
+	if code := runLint([]string{file}, &stdout, &stderr); code != exitParseError {
+		t.Errorf("expected exit code %d for a parse error, got %d", exitParseError, code)
+	}
+}
@@ -0,0 +1,684 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LSP constants for the kinds the server produces
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspSymbolFunction = 12
+	lspSymbolVariable = 13
+
+	lspCompletionFunction = 3
+	lspCompletionVariable = 6
+	lspCompletionKeyword  = 14
+
+	lspSyncFull = 1
+)
+
+// lspPosition is a zero-based line and character offset
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspHover struct {
+	Contents lspMarkupContent `json:"contents"`
+	Range    *lspRange        `json:"range,omitempty"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes map[string][]lspTextEdit `json:"changes"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Position     lspPosition               `json:"position"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspReferenceParams struct {
+	lspPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type lspRenameParams struct {
+	lspPositionParams
+	NewName string `json:"newName"`
+}
+
+// lspDocument is an open file and the analysis of its current text
+type lspDocument struct {
+	uri   string
+	text  string
+	lines []string
+
+	// program and analysis are nil while the text does not parse
+	program     *Program
+	analysis    *linter
+	diagnostics []lspDiagnostic
+}
+
+// lspServer answers Language Server Protocol requests for .tiny files
+type lspServer struct {
+	conn     *rpcConn
+	docs     map[string]*lspDocument
+	shutdown bool
+}
+
+// runLSP implements "tinylang lsp", serving LSP over the given streams
+// until the client sends exit. It returns the process exit code.
+func runLSP(stdin io.Reader, stdout, stderr io.Writer) int {
+	s := &lspServer{conn: newRPCConn(stdin, stdout), docs: map[string]*lspDocument{}}
+
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(stderr, "lsp: %v\n", err)
+			}
+			return 1
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.conn.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+			fmt.Fprintf(stderr, "lsp: %v\n", err)
+			return 1
+		}
+	}
+}
+
+// handle dispatches one message, returning the result for requests
+func (s *lspServer) handle(msg rpcMessage) (interface{}, *rpcError) {
+	if s.shutdown && msg.ID != nil {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           lspSyncFull,
+				"definitionProvider":         true,
+				"referencesProvider":         true,
+				"hoverProvider":              true,
+				"documentSymbolProvider":     true,
+				"completionProvider":         map[string]interface{}{},
+				"renameProvider":             true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "tinylang"},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.publishDiagnostics(params.TextDocument.URI, []lspDiagnostic{})
+		return nil, nil
+
+	case "textDocument/definition":
+		return s.withPosition(msg.Params, (*lspDocument).definition)
+	case "textDocument/hover":
+		return s.withPosition(msg.Params, (*lspDocument).hover)
+	case "textDocument/completion":
+		return s.withPosition(msg.Params, (*lspDocument).completion)
+
+	case "textDocument/references":
+		var params lspReferenceParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return doc.references(params.Position, params.Context.IncludeDeclaration), nil
+
+	case "textDocument/rename":
+		var params lspRenameParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return doc.rename(params.Position, params.NewName)
+
+	case "textDocument/documentSymbol":
+		var params struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return doc.symbols(), nil
+
+	case "textDocument/formatting":
+		var params struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return doc.format()
+	}
+
+	if msg.ID == nil {
+		// Unknown notifications, such as initialized, are ignored
+		return nil, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+// withPosition decodes position parameters and runs fn on the document
+func (s *lspServer) withPosition(raw json.RawMessage, fn func(*lspDocument, lspPosition) interface{}) (interface{}, *rpcError) {
+	var params lspPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil, unknownDocument(params.TextDocument.URI)
+	}
+	return fn(doc, params.Position), nil
+}
+
+// invalidParams reports undecodable request parameters
+func invalidParams(err error) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+}
+
+// unknownDocument reports a request for a document that is not open
+func unknownDocument(uri string) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: "document not open: " + uri}
+}
+
+// update re-analyzes a document and publishes its diagnostics
+func (s *lspServer) update(uri, text string) {
+	doc := newLSPDocument(uri, text)
+	s.docs[uri] = doc
+	s.publishDiagnostics(uri, doc.diagnostics)
+}
+
+// publishDiagnostics sends the diagnostics for one document
+func (s *lspServer) publishDiagnostics(uri string, diagnostics []lspDiagnostic) {
+	s.conn.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+// newLSPDocument parses and analyzes text. Parse errors are reported on
+// their own; the resolver only runs on programs that parse.
+func newLSPDocument(uri, text string) *lspDocument {
+	doc := &lspDocument{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	l := New(text)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	doc.diagnostics = []lspDiagnostic{}
+	if len(p.Errors()) > 0 {
+		for _, d := range p.Diagnostics() {
+			doc.diagnostics = append(doc.diagnostics, doc.diagnostic(d, lspSeverityError))
+		}
+		return doc
+	}
+
+	config := DefaultLintConfig()
+	if path := uriPath(uri); path != "" {
+		if found, err := findLintConfig(filepath.Dir(path)); err == nil {
+			config = found
+		}
+	}
+
+	doc.program = program
+	doc.analysis = analyze(program, l.Comments(), config)
+	for _, d := range doc.analysis.diagnostics {
+		severity := lspSeverityWarning
+		if d.Rule == RuleUndefined || d.Rule == RuleArity {
+			severity = lspSeverityError
+		}
+		doc.diagnostics = append(doc.diagnostics, doc.diagnostic(d, severity))
+	}
+	return doc
+}
+
+// uriPath returns the file path of a file:// URI, or "" for other schemes
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// diagnostic converts an analysis diagnostic, spanning the word it
+// points at
+func (doc *lspDocument) diagnostic(d Diagnostic, severity int) lspDiagnostic {
+	start := lspPosition{Line: d.Line - 1, Character: d.Column - 1}
+	if start.Line < 0 {
+		start.Line = 0
+	}
+	if start.Character < 0 {
+		start.Character = 0
+	}
+
+	end := start
+	if start.Line < len(doc.lines) {
+		line := doc.lines[start.Line]
+		for end.Character < len(line) && (isLetter(line[end.Character]) || isDigit(line[end.Character])) {
+			end.Character++
+		}
+		if end.Character == start.Character && end.Character < len(line) {
+			end.Character++
+		}
+	}
+
+	return lspDiagnostic{
+		Range:    lspRange{Start: start, End: end},
+		Severity: severity,
+		Code:     d.Rule,
+		Source:   "tinylang",
+		Message:  d.Message,
+	}
+}
+
+// identifierRange returns the range an identifier covers in the source
+func identifierRange(id *Identifier) lspRange {
+	start := lspPosition{Line: id.Token.Line - 1, Character: id.Token.Column - 1}
+	return lspRange{Start: start, End: lspPosition{Line: start.Line, Character: start.Character + len(id.Value)}}
+}
+
+// bindingAt finds the resolved identifier under pos and its binding
+func (doc *lspDocument) bindingAt(pos lspPosition) (*Identifier, *lintBinding) {
+	if doc.analysis == nil {
+		return nil, nil
+	}
+	for id, b := range doc.analysis.refs {
+		r := identifierRange(id)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return id, b
+		}
+	}
+	return nil, nil
+}
+
+// wordAt returns the identifier-like word under pos
+func (doc *lspDocument) wordAt(pos lspPosition) string {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return ""
+	}
+	line := doc.lines[pos.Line]
+	start, end := pos.Character, pos.Character
+	if start > len(line) {
+		return ""
+	}
+	for start > 0 && (isLetter(line[start-1]) || isDigit(line[start-1])) {
+		start--
+	}
+	for end < len(line) && (isLetter(line[end]) || isDigit(line[end])) {
+		end++
+	}
+	return line[start:end]
+}
+
+// definition answers textDocument/definition
+func (doc *lspDocument) definition(pos lspPosition) interface{} {
+	_, b := doc.bindingAt(pos)
+	if b == nil {
+		return nil
+	}
+	return lspLocation{URI: doc.uri, Range: identifierRange(b.name)}
+}
+
+// references answers textDocument/references
+func (doc *lspDocument) references(pos lspPosition, includeDeclaration bool) []lspLocation {
+	locations := []lspLocation{}
+	_, b := doc.bindingAt(pos)
+	if b == nil {
+		return locations
+	}
+
+	if includeDeclaration {
+		locations = append(locations, lspLocation{URI: doc.uri, Range: identifierRange(b.name)})
+	}
+	for _, use := range b.uses {
+		locations = append(locations, lspLocation{URI: doc.uri, Range: identifierRange(use)})
+	}
+	sortLocations(locations)
+	return locations
+}
+
+// sortLocations orders locations by position
+func sortLocations(locations []lspLocation) {
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i].Range.Start, locations[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Character < b.Character
+	})
+}
+
+// hover answers textDocument/hover with the declaration of the name
+// under the cursor, including signatures and doc comments for functions
+func (doc *lspDocument) hover(pos lspPosition) interface{} {
+	id, b := doc.bindingAt(pos)
+	if b == nil {
+		word := doc.wordAt(pos)
+		if _, ok := builtins[word]; ok {
+			return lspHover{Contents: lspMarkupContent{Kind: "markdown", Value: "```tinylang\nbuiltin " + word + "\n```"}}
+		}
+		return nil
+	}
+
+	var value string
+	switch b.kind {
+	case functionBinding:
+		value = "```tinylang\nfunc " + b.fn.Name.Value + "(" + formatParameterList(b.fn) + ")\n```"
+		if b.fn.Doc != "" {
+			value += "\n\n" + b.fn.Doc
+		}
+	case letBinding:
+		value = "```tinylang\nlet " + b.name.Value + "\n```"
+	case parameterBinding:
+		value = "```tinylang\nparameter " + b.name.Value + "\n```"
+	case catchBinding:
+		value = "```tinylang\ncatch (" + b.name.Value + ")\n```"
+	}
+
+	r := identifierRange(id)
+	return lspHover{Contents: lspMarkupContent{Kind: "markdown", Value: value}, Range: &r}
+}
+
+// completion answers textDocument/completion with keywords, builtins and
+// the names visible from the cursor's scope
+func (doc *lspDocument) completion(pos lspPosition) interface{} {
+	items := map[string]lspCompletionItem{}
+	for word := range keywords {
+		items[word] = lspCompletionItem{Label: word, Kind: lspCompletionKeyword}
+	}
+	for name := range builtins {
+		items[name] = lspCompletionItem{Label: name, Kind: lspCompletionFunction, Detail: "builtin"}
+	}
+
+	if doc.analysis != nil {
+		for _, scope := range doc.analysis.scopes {
+			if scope.fn != nil && !rangeContains(functionRange(scope.fn), pos) {
+				continue
+			}
+			for _, b := range scope.bindings {
+				item := lspCompletionItem{Label: b.name.Value, Kind: lspCompletionVariable}
+				if b.kind == functionBinding {
+					item.Kind = lspCompletionFunction
+					item.Detail = "func " + b.name.Value + "(" + formatParameterList(b.fn) + ")"
+				}
+				items[b.name.Value] = item
+			}
+		}
+	}
+
+	list := make([]lspCompletionItem, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list
+}
+
+// functionRange spans a function declaration from func to its closing brace
+func functionRange(fs *FunctionStatement) lspRange {
+	return lspRange{
+		Start: lspPosition{Line: fs.Token.Line - 1, Character: fs.Token.Column - 1},
+		End:   lspPosition{Line: fs.Body.RBrace.Line - 1, Character: fs.Body.RBrace.Column},
+	}
+}
+
+// rangeContains reports whether pos lies within r
+func rangeContains(r lspRange, pos lspPosition) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Character < r.Start.Character {
+		return false
+	}
+	if pos.Line == r.End.Line && pos.Character > r.End.Character {
+		return false
+	}
+	return true
+}
+
+// rename answers textDocument/rename by editing the declaration and every
+// reference of the name under the cursor
+func (doc *lspDocument) rename(pos lspPosition, newName string) (interface{}, *rpcError) {
+	if !isIdentifierName(newName) {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("%q is not a valid identifier", newName)}
+	}
+	_, b := doc.bindingAt(pos)
+	if b == nil {
+		return nil, &rpcError{Code: rpcRequestFailed, Message: "no symbol to rename at this position"}
+	}
+
+	edits := []lspTextEdit{}
+	for _, loc := range doc.references(lspPosition{Line: b.name.Token.Line - 1, Character: b.name.Token.Column - 1}, true) {
+		edits = append(edits, lspTextEdit{Range: loc.Range, NewText: newName})
+	}
+	return lspWorkspaceEdit{Changes: map[string][]lspTextEdit{doc.uri: edits}}, nil
+}
+
+// isIdentifierName reports whether name can be used as an identifier
+func isIdentifierName(name string) bool {
+	if name == "" || !isLetter(name[0]) || LookupIdent(name) != IDENT {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isLetter(name[i]) && !isDigit(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// symbols answers textDocument/documentSymbol with the functions and let
+// bindings of the document, nesting those declared inside functions
+func (doc *lspDocument) symbols() []lspDocumentSymbol {
+	if doc.program == nil {
+		return []lspDocumentSymbol{}
+	}
+	return doc.statementSymbols(doc.program.Statements)
+}
+
+// statementSymbols collects symbols from a statement list
+func (doc *lspDocument) statementSymbols(stmts []Statement) []lspDocumentSymbol {
+	symbols := []lspDocumentSymbol{}
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *FunctionStatement:
+			symbols = append(symbols, lspDocumentSymbol{
+				Name:           s.Name.Value,
+				Detail:         "func " + s.Name.Value + "(" + formatParameterList(s) + ")",
+				Kind:           lspSymbolFunction,
+				Range:          functionRange(s),
+				SelectionRange: identifierRange(s.Name),
+				Children:       doc.statementSymbols(s.Body.Statements),
+			})
+		case *LetStatement:
+			end := nodeEndLine(s) - 1
+			symbols = append(symbols, lspDocumentSymbol{
+				Name: s.Name.Value,
+				Kind: lspSymbolVariable,
+				Range: lspRange{
+					Start: lspPosition{Line: s.Token.Line - 1, Character: s.Token.Column - 1},
+					End:   lspPosition{Line: end, Character: doc.lineLength(end)},
+				},
+				SelectionRange: identifierRange(s.Name),
+			})
+		case *IfStatement:
+			symbols = append(symbols, doc.statementSymbols(s.Consequence.Statements)...)
+			if s.Alternative != nil {
+				symbols = append(symbols, doc.statementSymbols(s.Alternative.Statements)...)
+			}
+		case *TryStatement:
+			symbols = append(symbols, doc.statementSymbols(s.Block.Statements)...)
+			if s.Catch != nil {
+				symbols = append(symbols, doc.statementSymbols(s.Catch.Statements)...)
+			}
+			if s.Finally != nil {
+				symbols = append(symbols, doc.statementSymbols(s.Finally.Statements)...)
+			}
+		}
+	}
+	return symbols
+}
+
+// lineLength returns the length of a zero-based line
+func (doc *lspDocument) lineLength(line int) int {
+	if line < 0 || line >= len(doc.lines) {
+		return 0
+	}
+	return len(strings.TrimRight(doc.lines[line], "\r"))
+}
+
+// format answers textDocument/formatting with a single edit replacing
+// the whole document, or no edits when it is already formatted
+func (doc *lspDocument) format() (interface{}, *rpcError) {
+	formatted, err := Format(doc.text)
+	if err != nil {
+		return nil, &rpcError{Code: rpcRequestFailed, Message: err.Error()}
+	}
+	if formatted == doc.text {
+		return []lspTextEdit{}, nil
+	}
+
+	last := len(doc.lines) - 1
+	return []lspTextEdit{{
+		Range: lspRange{
+			End: lspPosition{Line: last, Character: len(doc.lines[last])},
+		},
+		NewText: formatted,
+	}}, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// lspTestClient drives an in-process language server over pipes
+type lspTestClient struct {
+	t      *testing.T
+	conn   *rpcConn
+	nextID int
+	done   chan int
+
+	// diagnostics holds the latest published diagnostics per URI
+	diagnostics map[string][]lspDiagnostic
+}
+
+func newLSPTestClient(t *testing.T) *lspTestClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &lspTestClient{
+		t:           t,
+		conn:        newRPCConn(clientIn, clientOut),
+		done:        make(chan int, 1),
+		diagnostics: map[string][]lspDiagnostic{},
+	}
+	go func() {
+		c.done <- runLSP(serverIn, serverOut, io.Discard)
+		serverOut.Close()
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+// call sends a request and decodes its result, collecting any
+// notifications that arrive first
+func (c *lspTestClient) call(method string, params interface{}, result interface{}) *rpcError {
+	c.t.Helper()
+	c.nextID++
+	if err := c.conn.call(c.nextID, method, params); err != nil {
+		c.t.Fatalf("sending %s: %v", method, err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.Method != "" {
+			continue
+		}
+		var id int
+		json.Unmarshal(msg.ID, &id)
+		if id != c.nextID {
+			c.t.Fatalf("%s: response id %d, want %d", method, id, c.nextID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: decoding result %s: %v", method, msg.Result, err)
+			}
+		}
+		return nil
+	}
+}
+
+// notify sends a notification and, for document changes, waits for the
+// diagnostics the server publishes in reply
+func (c *lspTestClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("sending %s: %v", method, err)
+	}
+	if strings.HasPrefix(method, "textDocument/did") {
+		for c.read().Method != "textDocument/publishDiagnostics" {
+		}
+	}
+}
+
+// read returns the next message from the server
+func (c *lspTestClient) read() rpcMessage {
+	c.t.Helper()
+	body, err := c.conn.read()
+	if err != nil {
+		c.t.Fatalf("reading from server: %v", err)
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("decoding %s: %v", body, err)
+	}
+	if msg.Method == "textDocument/publishDiagnostics" {
+		var params struct {
+			URI         string          `json:"uri"`
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		c.diagnostics[params.URI] = params.Diagnostics
+	}
+	return msg
+}
+
+func positionParams(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     lspPosition{Line: line, Character: character},
+	}
+}
+
+const lspTestURI = "file:///tmp/lsp_test.tiny"
+
+const lspTestSource = `// Adds two numbers.
+func add(a, b) {
+    return a + b;
+}
+
+let total = add(1, 2);
+print(total);
+`
+
+func TestLSPSession(t *testing.T) {
+	c := newLSPTestClient(t)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := c.call("initialize", map[string]interface{}{}, &init); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	for _, capability := range []string{"definitionProvider", "referencesProvider", "hoverProvider",
+		"documentSymbolProvider", "completionProvider", "renameProvider", "documentFormattingProvider"} {
+		if init.Capabilities[capability] == nil {
+			t.Errorf("missing capability %s", capability)
+		}
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": lspTestURI, "languageId": "tinylang", "version": 1, "text": lspTestSource},
+	})
+	if d := c.diagnostics[lspTestURI]; len(d) != 0 {
+		t.Errorf("expected no diagnostics, got %v", d)
+	}
+
+	// Definition of add from its call site
+	var loc lspLocation
+	c.call("textDocument/definition", positionParams(lspTestURI, 5, 13), &loc)
+	if loc.Range.Start != (lspPosition{Line: 1, Character: 5}) || loc.Range.End.Character != 8 {
+		t.Errorf("wrong definition: %+v", loc)
+	}
+
+	// References to the parameter a, including its declaration
+	var refs []lspLocation
+	c.call("textDocument/references", map[string]interface{}{
+		"textDocument": map[string]string{"uri": lspTestURI},
+		"position":     lspPosition{Line: 2, Character: 11},
+		"context":      map[string]bool{"includeDeclaration": true},
+	}, &refs)
+	if len(refs) != 2 || refs[0].Range.Start != (lspPosition{Line: 1, Character: 9}) ||
+		refs[1].Range.Start != (lspPosition{Line: 2, Character: 11}) {
+		t.Errorf("wrong references: %+v", refs)
+	}
+
+	// Hover shows the signature and doc comment
+	var hover lspHover
+	c.call("textDocument/hover", positionParams(lspTestURI, 5, 12), &hover)
+	if hover.Contents.Value != "```tinylang\nfunc add(a, b)\n```\n\nAdds two numbers." {
+		t.Errorf("wrong hover: %q", hover.Contents.Value)
+	}
+	c.call("textDocument/hover", positionParams(lspTestURI, 6, 1), &hover)
+	if !strings.Contains(hover.Contents.Value, "builtin print") {
+		t.Errorf("wrong builtin hover: %q", hover.Contents.Value)
+	}
+
+	// Document symbols
+	var symbols []lspDocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": lspTestURI}}, &symbols)
+	if len(symbols) != 2 || symbols[0].Name != "add" || symbols[0].Kind != lspSymbolFunction ||
+		symbols[0].Range.End.Line != 3 || symbols[1].Name != "total" || symbols[1].Kind != lspSymbolVariable {
+		t.Errorf("wrong symbols: %+v", symbols)
+	}
+
+	// Completion inside add sees its parameters, keywords and builtins
+	var items []lspCompletionItem
+	c.call("textDocument/completion", positionParams(lspTestURI, 2, 4), &items)
+	labels := map[string]bool{}
+	for _, item := range items {
+		labels[item.Label] = true
+	}
+	for _, want := range []string{"a", "b", "add", "total", "let", "func", "return", "len"} {
+		if !labels[want] {
+			t.Errorf("completion missing %q", want)
+		}
+	}
+	c.call("textDocument/completion", positionParams(lspTestURI, 6, 0), &items)
+	for _, item := range items {
+		if item.Label == "a" {
+			t.Errorf("parameter a offered outside its function")
+		}
+	}
+
+	// Rename total everywhere
+	var edit lspWorkspaceEdit
+	if err := c.call("textDocument/rename", map[string]interface{}{
+		"textDocument": map[string]string{"uri": lspTestURI},
+		"position":     lspPosition{Line: 6, Character: 7},
+		"newName":      "sum",
+	}, &edit); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	edits := edit.Changes[lspTestURI]
+	if len(edits) != 2 || edits[0].Range.Start.Line != 5 || edits[1].Range.Start.Line != 6 || edits[0].NewText != "sum" {
+		t.Errorf("wrong rename edits: %+v", edits)
+	}
+	if err := c.call("textDocument/rename", map[string]interface{}{
+		"textDocument": map[string]string{"uri": lspTestURI},
+		"position":     lspPosition{Line: 6, Character: 7},
+		"newName":      "let",
+	}, nil); err == nil || err.Code != rpcInvalidParams {
+		t.Errorf("expected invalid params error renaming to a keyword, got %v", err)
+	}
+
+	// Formatting
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": lspTestURI, "version": 2},
+		"contentChanges": []map[string]string{{"text": "let x=1;\nprint(x);"}},
+	})
+	var formatEdits []lspTextEdit
+	c.call("textDocument/formatting", map[string]interface{}{"textDocument": map[string]string{"uri": lspTestURI}}, &formatEdits)
+	if len(formatEdits) != 1 || formatEdits[0].NewText != "let x = 1;\nprint(x);\n" ||
+		formatEdits[0].Range.End != (lspPosition{Line: 1, Character: 9}) {
+		t.Errorf("wrong formatting edits: %+v", formatEdits)
+	}
+
+	// Diagnostics from the parser and the resolver
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": lspTestURI, "version": 3},
+		"contentChanges": []map[string]string{{"text": "print(missing);\n"}},
+	})
+	d := c.diagnostics[lspTestURI]
+	if len(d) != 1 || d[0].Code != RuleUndefined || d[0].Severity != lspSeverityError ||
+		d[0].Range != (lspRange{Start: lspPosition{0, 6}, End: lspPosition{0, 13}}) {
+		t.Errorf("wrong resolver diagnostics: %+v", d)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": lspTestURI, "version": 4},
+		"contentChanges": []map[string]string{{"text": "let x = 1;\nlet = 2;\n"}},
+	})
+	d = c.diagnostics[lspTestURI]
+	if len(d) == 0 || d[0].Code != "syntax" || d[0].Range.Start.Line != 1 {
+		t.Errorf("wrong parse diagnostics: %+v", d)
+	}
+
+	if err := c.call("textDocument/unknownThing", map[string]interface{}{}, nil); err == nil || err.Code != rpcMethodNotFound {
+		t.Errorf("expected method not found, got %v", err)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.conn.notify("exit", nil)
+	if code := <-c.done; code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+}
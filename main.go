@@ -3,22 +3,68 @@ package main
 import (
 	"fmt"
 	"os"
-	"strings"
 )
 
+// Version is the interpreter version printed by "tinylang version"
+const Version = "0.9.0"
+
+// usage lists the subcommands
+const usage = `Usage: tinylang <command> [arguments]
+
+Commands:
+  run [flags] <file.tiny | -> [args...]          run a program
+  eval [flags] -e <program> [args...]            run a program given on the command line
+  check [-format human|json|github] [file.tiny | dir | - ...]
+                                                 find errors without running
+  test [-v] [-run regexp] [-junit file] [-cover] [file_test.tiny | dir ...]
+  fmt [-w] [-d] [file.tiny ...]
+  lint [-config file] [file.tiny | dir ...]
+  tokens [-format text|json] <file.tiny>
+  ast [-format text|json] <file.tiny>
+  debug <file.tiny>
+  dap
+  lsp
+  version
+
+"tinylang file.tiny" is short for "tinylang run file.tiny".
+Exit codes: 0 success, 1 runtime error or failed check, 2 usage error, 3 parse or compile error, 4 limit exceeded.`
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: tinylang <file.tiny>")
-		return
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(exitUsage)
 	}
 
-	filename := os.Args[1]
-	if !strings.HasSuffix(filename, ".tiny") {
-		fmt.Println("Error: File must have .tiny extension")
-		return
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runRun(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	case "eval":
+		os.Exit(runEval(os.Args[2:], os.Stdout, os.Stderr))
+	case "check":
+		os.Exit(runCheck(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	case "version":
+		fmt.Println("tinylang " + Version)
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+	case "test":
+		os.Exit(runTest(os.Args[2:], os.Stdout, os.Stderr))
+	case "fmt":
+		os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	case "lint":
+		os.Exit(runLint(os.Args[2:], os.Stdout, os.Stderr))
+	case "lsp":
+		os.Exit(runLSP(os.Stdin, os.Stdout, os.Stderr))
+	case "debug":
+		os.Exit(runDebug(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	case "dap":
+		os.Exit(runDAP(os.Stdin, os.Stdout, os.Stderr))
+	case "tokens":
+		os.Exit(runTokens(os.Args[2:], os.Stdout, os.Stderr))
+	case "ast":
+		os.Exit(runAST(os.Args[2:], os.Stdout, os.Stderr))
+	default:
+		os.Exit(runRun(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 	}
-
-	RunFile(filename)
 }
 
 // getStatementType returns a human-readable description of the statement type
@@ -38,6 +84,15 @@ func getStatementType(stmt Statement) string {
 			return "If-Else Statement"
 		}
 		return "If Statement"
+	case *TryStatement:
+		if s.Catch != nil && s.Finally != nil {
+			return "Try-Catch-Finally Statement"
+		} else if s.Catch != nil {
+			return "Try-Catch Statement"
+		}
+		return "Try-Finally Statement"
+	case *ThrowStatement:
+		return "Throw Statement"
 	case *ExpressionStatement:
 		return fmt.Sprintf("Expression Statement (%s)", getExpressionType(s.Expression))
 	case *BlockStatement:
@@ -53,9 +108,14 @@ func getExpressionType(expr Expression) string {
 	case *Identifier:
 		return fmt.Sprintf("Identifier (%s)", e.Value)
 	case *IntegerLiteral:
+		if e.Big != nil {
+			return fmt.Sprintf("Integer (%s)", e.Big)
+		}
 		return fmt.Sprintf("Integer (%d)", e.Value)
 	case *StringLiteral:
 		return fmt.Sprintf("String (%q)", e.Value)
+	case *DecimalLiteral:
+		return fmt.Sprintf("Decimal (%s)", e.Value.Inspect())
 	case *BooleanLiteral:
 		return fmt.Sprintf("Boolean (%t)", e.Value)
 	case *PrefixExpression:
@@ -64,6 +124,16 @@ func getExpressionType(expr Expression) string {
 		return fmt.Sprintf("Infix (%s)", e.Operator)
 	case *CallExpression:
 		return fmt.Sprintf("Call (%d args)", len(e.Arguments))
+	case *IndexExpression:
+		return "Index"
+	case *NamedArgument:
+		return fmt.Sprintf("Named Argument (%s)", e.Name.Value)
+	case *PropertyExpression:
+		return fmt.Sprintf("Property (%s)", e.Property.Value)
+	case *PropagateExpression:
+		return "Propagate (?)"
+	case *SpawnExpression:
+		return fmt.Sprintf("Spawn (%d args)", len(e.Call.Arguments))
 	default:
 		return "Unknown Expression"
 	}
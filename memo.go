@@ -0,0 +1,136 @@
+package main
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func init() {
+	builtins["memo"] = &Builtin{Name: "memo", Fn: builtinMemo}
+}
+
+// memoCache holds the results of a memoized function, keyed on its
+// arguments. With a size limit, the least recently used result is evicted
+// to make room for a new one.
+type memoCache struct {
+	mu      sync.Mutex
+	size    int // 0 for no limit
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+// memoEntry is a cached result
+type memoEntry struct {
+	key    string
+	result Object
+}
+
+// builtinMemo implements memo(fn) and memo(fn, size). It returns a
+// function that calls fn once for each distinct list of arguments and
+// then answers from a cache. Redeclaring a recursive function with its
+// memoized version makes the recursive calls use the cache too:
+//
+//	let fib = memo(fib);
+func builtinMemo(_ *Environment, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newErrorKind(ARGUMENT_ERROR, "wrong number of arguments to memo: got=%d, want=1 or 2", len(args))
+	}
+	fn, ok := args[0].(*Function)
+	if !ok {
+		return newErrorKind(TYPE_ERROR, "first argument to memo must be FUNCTION, got %s", args[0].Type())
+	}
+	cache := &memoCache{entries: map[string]*list.Element{}, order: list.New()}
+	if len(args) == 2 {
+		size, ok := args[1].(*Integer)
+		if !ok {
+			return newErrorKind(TYPE_ERROR, "size given to memo must be INTEGER, got %s", args[1].Type())
+		}
+		if size.Value < 1 {
+			return newErrorKind(VALUE_ERROR, "size given to memo must be positive, got %d", size.Value)
+		}
+		cache.size = int(size.Value)
+	}
+
+	return &Builtin{Name: "memo(" + fn.Name + ")", Fn: func(env *Environment, args ...Object) Object {
+		key, err := memoKey(fn, args)
+		if err != nil {
+			return err
+		}
+		if result, ok := cache.get(key); ok {
+			return result
+		}
+		// The lock is not held during the call, which may recurse into
+		// the cache
+		result := applyFunction(fn, args, env)
+		if !isError(result) {
+			cache.put(key, result)
+		}
+		return result
+	}}
+}
+
+// memoKey encodes the arguments of a call to a memoized function. Only
+// numbers, strings, booleans and null can be keys: other values are
+// compared by identity, so equal ones would miss the cache.
+func memoKey(fn *Function, args []Object) (string, *Error) {
+	var key strings.Builder
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case *Integer:
+			key.WriteString("i")
+			key.WriteString(strconv.FormatInt(arg.Value, 10))
+		case *BigInt:
+			key.WriteString("i")
+			key.WriteString(arg.Value.String())
+		case *Decimal:
+			// the scale is part of the key, as results may depend on it:
+			// 1.00d and 1d are equal but print differently
+			key.WriteString("d")
+			key.WriteString(arg.Inspect())
+		case *String:
+			key.WriteString("s")
+			key.WriteString(strconv.Itoa(len(arg.Value)))
+			key.WriteString(":")
+			key.WriteString(arg.Value)
+		case *Boolean:
+			key.WriteString(strconv.FormatBool(arg.Value))
+		case *Null:
+			key.WriteString("null")
+		default:
+			return "", newErrorKind(TYPE_ERROR, "unhashable argument to %s: %s", fn.Name, arg.Type())
+		}
+		key.WriteString(";")
+	}
+	return key.String(), nil
+}
+
+// get returns the cached result for key and marks it as recently used
+func (c *memoCache) get(key string) (Object, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*memoEntry).result, true
+}
+
+// put caches a result, evicting the least recently used one if the cache
+// is full
+func (c *memoCache) put(key string, result Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoEntry{key: key, result: result})
+	if c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoEntry).key)
+	}
+}
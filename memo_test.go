@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestMemo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`
+func fib(n) {
+	if (n <= 1) { return n; }
+	return fib(n - 1) + fib(n - 2);
+}
+let fib = memo(fib);
+fib(90);`, "2880067194370816120"},
+		{listFunc + `
+func square(n) { print(n); return n * n; }
+let square = memo(square);
+list(square(3), square(3), square(4), square(3));`, "3\n4\n[9, 9, 16, 9]"},
+		{`
+func greet(name, loud) { print(name); return name; }
+let greet = memo(greet);
+greet("a", true); greet("a", false); greet("a", true); greet("b", true);
+greet("a", true);`, "a\na\nb\na"},
+		{`
+func square(n) { print(n); return n * n; }
+let square = memo(square, 2);
+square(1); square(2); square(1); square(3); square(1); square(2);`, "1\n2\n3\n2\n4"},
+		{`
+func check(n) { print(n); if (n < 0) { throw "negative"; } return ok(n); }
+let check = memo(check);
+try { check(-1); } catch (e) { print(e.message); }
+try { check(-1); } catch (e) { print(e.message); }
+check(1); check(1);`, "-1\nnegative\n-1\nnegative\n1\nok(1)"},
+		{listFunc + `
+func first(a) { return a[0]; }
+let first = memo(first);
+try { first(list(1, 2)); } catch (e) { print(e.kind); }
+first(list(1, 2));`, "TypeError\nERROR: unhashable argument to first: ARRAY"},
+		{`
+func id(d) { print(d); return d; }
+let id = memo(id);
+id(100d); id(10d); id(1d); id(1.0d); id(10.00d); id(0.10d); id(1.00d); id(1.0d);
+str(id(1.00d));`, "100\n10\n1\n1.0\n10.00\n0.10\n1.00\n1.00"},
+		{"memo(len);", "ERROR: first argument to memo must be FUNCTION, got BUILTIN"},
+		{"func f() { return 1; } memo(f, 0);", "ERROR: size given to memo must be positive, got 0"},
+		{"func f() { return 1; } memo(f, \"2\");", "ERROR: size given to memo must be INTEGER, got STRING"},
+		{"memo();", "ERROR: wrong number of arguments to memo: got=0, want=1 or 2"},
+	}
+
+	for _, tt := range tests {
+		if got := runOptimized(t, tt.input, false); got != tt.expected {
+			t.Errorf("%s\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
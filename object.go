@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -10,12 +11,20 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ  = "INTEGER"
+	DECIMAL_OBJ  = "DECIMAL"
 	BOOLEAN_OBJ  = "BOOLEAN"
 	STRING_OBJ   = "STRING"
 	NULL_OBJ     = "NULL"
 	RETURN_OBJ   = "RETURN_VALUE"
+	TAIL_OBJ     = "TAIL_CALL"
 	ERROR_OBJ    = "ERROR"
 	FUNCTION_OBJ = "FUNCTION"
+	BUILTIN_OBJ  = "BUILTIN"
+	TASK_OBJ     = "TASK"
+	CHANNEL_OBJ  = "CHANNEL"
+	ERR_OBJ      = "ERR"
+	OK_OBJ       = "OK"
+	ARRAY_OBJ    = "ARRAY"
 )
 
 // Object represents any value in the TinyLang runtime
@@ -32,6 +41,38 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+// BigInt is an integer that does not fit in 64 bits. It is only created
+// by runs that promote overflowing arithmetic, and is an INTEGER to
+// programs: values that fit in 64 bits are always Integers.
+type BigInt struct {
+	Value *big.Int // never modified
+}
+
+func (b *BigInt) Type() ObjectType { return INTEGER_OBJ }
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+
+// Decimal is an exact decimal number: Value scaled down by Scale decimal
+// digits, so 12.50 is 1250 with scale 2
+type Decimal struct {
+	Value *big.Int // never modified
+	Scale int
+}
+
+func (d *Decimal) Type() ObjectType { return DECIMAL_OBJ }
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Value).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
 // Boolean represents boolean values
 type Boolean struct {
 	Value bool
@@ -62,17 +103,90 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
-// Error represents runtime errors
+// tailCall is the value returned by a function whose return statement
+// calls another function. The call is made by applyFunctionNamed after
+// the returning function's frame is gone.
+type tailCall struct {
+	node  *CallExpression
+	fn    *Function
+	args  []Object
+	named []namedArgument
+	env   *Environment // scope of the return statement
+}
+
+func (tc *tailCall) Type() ObjectType { return TAIL_OBJ }
+func (tc *tailCall) Inspect() string  { return "tail call to " + tc.fn.Name }
+
+// Error kinds classify runtime errors so that scripts can tell them apart
+const (
+	RUNTIME_ERROR       = "RuntimeError"
+	TYPE_ERROR          = "TypeError"
+	NAME_ERROR          = "NameError"
+	ARGUMENT_ERROR      = "ArgumentError"
+	VALUE_ERROR         = "ValueError"
+	INDEX_ERROR         = "IndexError"
+	ZERO_DIVISION_ERROR = "ZeroDivisionError"
+	DEADLOCK_ERROR      = "DeadlockError"
+	THROWN_ERROR        = "Error"
+	ASSERTION_ERROR     = "AssertionError"
+	LIMIT_ERROR         = "LimitError"
+	OVERFLOW_ERROR      = "OverflowError"
+)
+
+// Error represents runtime errors. Line and Column locate the expression
+// that failed and are zero until the error is positioned.
 type Error struct {
 	Message string
+	Kind    string
+	Line    int
+	Column  int
+
+	// reported is set once the error has been passed to OnError
+	reported bool
+	// debuggerQuit marks the error ending a program from the debugger
+	debuggerQuit bool
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
+// Err holds an error as an ordinary value, such as the error bound by a
+// catch clause. Unlike Error it does not abort evaluation.
+type Err struct {
+	Error *Error
+}
+
+func (e *Err) Type() ObjectType { return ERR_OBJ }
+func (e *Err) Inspect() string  { return fmt.Sprintf("err(%q)", e.Error.Message) }
+
+// Ok wraps the value of a successful operation, the counterpart of Err
+type Ok struct {
+	Value Object
+}
+
+func (o *Ok) Type() ObjectType { return OK_OBJ }
+func (o *Ok) Inspect() string  { return "ok(" + o.Value.Inspect() + ")" }
+
+// Array represents an ordered list of values
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.Inspect())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
 // Function represents function values
 type Function struct {
+	Name       string
 	Parameters []*Identifier
+	Defaults   []Expression
+	Rest       *Identifier
 	Body       *BlockStatement
 	Env        *Environment
 }
@@ -81,14 +195,9 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out strings.Builder
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("func")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(formatParameters(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -96,6 +205,49 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// BuiltinFunction is the Go implementation of a builtin. env is the
+// scope of the call site.
+type BuiltinFunction func(env *Environment, args ...Object) Object
+
+// Builtin represents functions implemented by the interpreter itself
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin " + b.Name }
+
+// Task represents a function call running on its own goroutine
+type Task struct {
+	ID     int
+	done   bool
+	result Object
+	await  []*waiter
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return fmt.Sprintf("task#%d", t.ID) }
+
+// Channel represents a typed queue used to pass values between tasks.
+// An empty ElemType accepts values of any type.
+type Channel struct {
+	ID       int
+	ElemType ObjectType
+	Capacity int
+	buffer   []Object
+	recvq    []*waiter
+	sendq    []*waiter
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string {
+	if c.ElemType == "" {
+		return fmt.Sprintf("chan#%d", c.ID)
+	}
+	return fmt.Sprintf("chan#%d<%s>", c.ID, c.ElemType)
+}
+
 // Predefined objects for commonly used values
 var (
 	NULL          = &Null{}
@@ -103,6 +255,29 @@ var (
 	RUNTIME_FALSE = &Boolean{Value: false}
 )
 
+// Integers from minCachedInt to maxCachedInt are created once and shared,
+// as counters, indexes and lengths are the values programs compute most
+const (
+	minCachedInt = -128
+	maxCachedInt = 1024
+)
+
+var smallIntegers = func() (cache [maxCachedInt - minCachedInt + 1]Integer) {
+	for i := range cache {
+		cache[i].Value = int64(i + minCachedInt)
+	}
+	return cache
+}()
+
+// newInteger returns an integer object, without allocating for small
+// values
+func newInteger(value int64) *Integer {
+	if value >= minCachedInt && value <= maxCachedInt {
+		return &smallIntegers[value-minCachedInt]
+	}
+	return &Integer{Value: value}
+}
+
 // Helper function to check if object is truthy
 func isTruthy(obj Object) bool {
 	switch obj {
@@ -119,5 +294,10 @@ func isTruthy(obj Object) bool {
 
 // Helper function to create new error objects
 func newError(format string, a ...interface{}) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+	return newErrorKind(RUNTIME_ERROR, format, a...)
+}
+
+// Helper function to create new error objects of a specific kind
+func newErrorKind(kind string, format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: kind}
 }
@@ -0,0 +1,193 @@
+package main
+
+import "strconv"
+
+// optimizer rewrites a program before it runs. It folds operators whose
+// operands are all literals and drops the branches of if statements
+// whose condition is a literal. Constant expressions are evaluated by the
+// evaluator itself, so folding never changes what a program computes.
+type optimizer struct {
+	env    *Environment // evaluates constant expressions
+	errors []*Error
+
+	// uncertain counts the enclosing code that may never run: function
+	// bodies, branches of conditions that are not literals, and try and
+	// catch blocks. Errors there are left for the program to raise if it
+	// gets there.
+	uncertain int
+}
+
+// Optimize rewrites a program in place and returns the errors its
+// constant expressions are bound to raise, such as a literal division by
+// zero in top-level code. Expressions that fail are left unfolded.
+func Optimize(program *Program) []*Error {
+	o := &optimizer{env: NewEnvironment()}
+	// Whether a result may exceed 64 bits depends on the run, so an
+	// overflow yields a BigInt, which is left unfolded
+	o.env.rt.SetOverflow(OverflowBigInt)
+	program.Statements = o.statements(program.Statements)
+	return o.errors
+}
+
+// statements optimizes a statement list, reusing its backing array
+func (o *optimizer) statements(stmts []Statement) []Statement {
+	out := stmts[:0]
+	for i, stmt := range stmts {
+		if stmt = o.statement(stmt, i == len(stmts)-1); stmt != nil {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+// block optimizes the statements of a block, if there is one
+func (o *optimizer) block(block *BlockStatement) {
+	if block != nil {
+		block.Statements = o.statements(block.Statements)
+	}
+}
+
+// statement optimizes one statement and returns its replacement, or nil
+// if it can be dropped. last says whether the statement's value may be
+// the value of its block.
+func (o *optimizer) statement(stmt Statement, last bool) Statement {
+	switch s := stmt.(type) {
+	case *LetStatement:
+		s.Value = o.expression(s.Value)
+	case *FunctionStatement:
+		o.uncertain++
+		for i, def := range s.Defaults {
+			if def != nil {
+				s.Defaults[i] = o.expression(def)
+			}
+		}
+		o.block(s.Body)
+		o.uncertain--
+	case *ReturnStatement:
+		if s.ReturnValue != nil {
+			s.ReturnValue = o.expression(s.ReturnValue)
+		}
+	case *ThrowStatement:
+		s.Value = o.expression(s.Value)
+	case *ExpressionStatement:
+		s.Expression = o.expression(s.Expression)
+	case *IfStatement:
+		return o.ifStatement(s, last)
+	case *TryStatement:
+		o.uncertain++
+		o.block(s.Block)
+		o.block(s.Catch)
+		o.uncertain--
+		o.block(s.Finally)
+	case *BlockStatement:
+		o.block(s)
+	}
+	return stmt
+}
+
+// ifStatement replaces an if statement whose condition is a literal with
+// the branch it always takes. Blocks share the scope around them, so this
+// keeps every name where it was. The branch never taken is dropped
+// without being optimized, so its errors are not reported.
+func (o *optimizer) ifStatement(s *IfStatement, last bool) Statement {
+	s.Condition = o.expression(s.Condition)
+	if !isLiteral(s.Condition) {
+		o.uncertain++
+		o.block(s.Consequence)
+		o.block(s.Alternative)
+		o.uncertain--
+		return s
+	}
+
+	switch {
+	case isTruthy(Eval(s.Condition, o.env)):
+		o.block(s.Consequence)
+		return s.Consequence
+	case s.Alternative != nil:
+		o.block(s.Alternative)
+		return s.Alternative
+	case last:
+		// the statement still has to produce its null value
+		s.Consequence.Statements = nil
+		return s
+	}
+	return nil
+}
+
+// expression folds the constant parts of an expression and returns the
+// result
+func (o *optimizer) expression(exp Expression) Expression {
+	switch e := exp.(type) {
+	case *PrefixExpression:
+		e.Right = o.expression(e.Right)
+		if isLiteral(e.Right) {
+			return o.fold(e, e.Token)
+		}
+	case *InfixExpression:
+		e.Left = o.expression(e.Left)
+		e.Right = o.expression(e.Right)
+		if isLiteral(e.Left) && isLiteral(e.Right) {
+			return o.fold(e, e.Token)
+		}
+	case *CallExpression:
+		e.Function = o.expression(e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = o.expression(arg)
+		}
+	case *SpawnExpression:
+		o.expression(e.Call)
+	case *NamedArgument:
+		e.Value = o.expression(e.Value)
+	case *IndexExpression:
+		e.Left = o.expression(e.Left)
+		e.Index = o.expression(e.Index)
+	case *PropertyExpression:
+		e.Object = o.expression(e.Object)
+	case *PropagateExpression:
+		e.Value = o.expression(e.Value)
+	}
+	return exp
+}
+
+// fold evaluates an operator applied to literals and returns the literal
+// for its value, positioned at tok. An operation that fails is reported
+// and kept, so it still fails when the program reaches it.
+func (o *optimizer) fold(exp Expression, tok Token) Expression {
+	value := Eval(exp, o.env)
+	if err, ok := value.(*Error); ok {
+		if o.uncertain == 0 {
+			o.errors = append(o.errors, err)
+		}
+		return exp
+	}
+
+	pos := Token{Line: tok.Line, Column: tok.Column}
+	switch v := value.(type) {
+	case *Integer:
+		pos.Type, pos.Literal = INT, strconv.FormatInt(v.Value, 10)
+		return &IntegerLiteral{Token: pos, Value: v.Value}
+	case *String:
+		pos.Type, pos.Literal = STRING, v.Value
+		return &StringLiteral{Token: pos, Value: v.Value}
+	case *Boolean:
+		pos.Type, pos.Literal = FALSE, "false"
+		if v.Value {
+			pos.Type, pos.Literal = TRUE, "true"
+		}
+		return &BooleanLiteral{Token: pos, Value: v.Value}
+	}
+	return exp
+}
+
+// isLiteral reports whether exp is an integer, string or boolean literal.
+// Integer literals past 64 bits are not, as their value depends on the
+// run.
+func isLiteral(exp Expression) bool {
+	switch exp := exp.(type) {
+	case *IntegerLiteral:
+		return exp.Big == nil
+	case *StringLiteral, *BooleanLiteral:
+		return true
+	}
+	return false
+}
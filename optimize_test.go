@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 2 * 3 + 4;", "let x = 10;"},
+		{"let s = \"a\" + \"b\";", "let s = \"ab\";"},
+		{"!true;", "false;"},
+		{"-(2 * 3);", "-6;"},
+		{"let r = salary * (15 * 2) / 100;", "let r = ((salary * 30) / 100);"},
+		{"1 < 2 && \"a\" == \"a\";", "true;"},
+		{"f(1 + 1, g(2 * 2), x: 3 - 1)[0 + 1];", "(f(2, g(4), x: 2)[1]);"},
+		{"func f(a = 60 * 60) { return a * (1 + 1); }", "func f(a = 3600) { return (a * 2); }"},
+		{"if (1 > 2) { print(1); } else { print(2); }", "{ print(2); }"},
+		{"if (true) { print(1); }", "{ print(1); }"},
+		{"if (false) { print(1); } print(2);", "print(2);"},
+		{"print(2); if (false) { print(1); }", "print(2);if (false) { }"},
+		{"if (x) { let y = 1 + 1; }", "if (x) { let y = 2; }"},
+		{"try { 1 / 0; } catch (e) { 2 + 2; }", "try { (1 / 0); } catch (e) { 4; }"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if errors := Optimize(program); len(errors) > 0 {
+			t.Errorf("%q: unexpected errors %v", tt.input, errors)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestOptimizeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 10 / (5 - 5);", []string{"1:12: ZeroDivisionError: division by zero"}},
+		{"if (1 / 0) { 1; }\nlet y = -\"a\";", []string{
+			"1:7: ZeroDivisionError: division by zero",
+			"2:9: TypeError: unknown operator: -STRING",
+		}},
+		{"if (true) { 1 / 0; } else { 2 / 0; }", []string{"1:15: ZeroDivisionError: division by zero"}},
+		{"try { 1; } finally { \"a\" - 1; }", []string{"1:26: TypeError: type mismatch: STRING - INTEGER"}},
+		{"try { 1 / 0; } catch (e) { 1 / 0; }", nil},
+		{"func f() { return \"a\" - 1; }", nil},
+		{"func f(a = 1 / 0) { return a; }", nil},
+		{"if (false) { 1 / 0; } 3", nil},
+		{"if (x) { 1 / 0; } else { 2 / 0; }", nil},
+		{"let x = y / 0;", nil},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var got []string
+		for _, err := range Optimize(program) {
+			got = append(got, strings.TrimPrefix(runtimeErrorMessage("", err), ":"))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: expected errors %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// runOptimized evaluates a program with or without the optimizer and
+// returns everything it printed followed by its value
+func runOptimized(t *testing.T, source string, optimize bool) string {
+	p := NewParser(New(source))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if optimize {
+		if errors := Optimize(program); len(errors) > 0 {
+			t.Fatalf("unexpected compile errors %v", errors)
+		}
+	}
+
+	var out bytes.Buffer
+	env := NewEnvironment()
+	env.rt.SetOutput(&out)
+	if result := Eval(program, env); result != nil {
+		out.WriteString(result.Inspect())
+	}
+	return out.String()
+}
+
+func TestOptimizePreservesSemantics(t *testing.T) {
+	sources := map[string]string{
+		"ifs": listFunc + `func sign(n) {
+    if (0 > 1) { return 99; }
+    if (n < 0) { return -1; } else { if (true) { return 1 * 1; } }
+}
+let parts = list(sign(-5), sign(5), "x" + str(2 * 21));
+if (false) { print("never"); }
+if (!false) { print(parts); }
+if (1 == 2) { 0; }`,
+		"try": listFunc + `func f() { try { let a = 10 / (2 - 2); } catch (e) { return e.kind + "!"; } }
+list(f(), 2 > 1 || false, 3 - 5, -(-4));`,
+		"unreached": `if (false) { 1 / 0; }
+func half(n) { return n / 0; }
+func safe() { try { return half(1); } catch (e) { return e.kind; } }
+safe();`,
+	}
+	examples, _ := filepath.Glob(filepath.Join("examples", "*.tiny"))
+	for _, path := range examples {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[path] = string(content)
+	}
+
+	for name, source := range sources {
+		plain := runOptimized(t, source, false)
+		optimized := runOptimized(t, source, true)
+		if plain != optimized {
+			t.Errorf("%s: output changed by the optimizer.\nwithout:\n%s\nwith:\n%s", name, plain, optimized)
+		}
+	}
+}
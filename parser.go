@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // Parser precedence constants
@@ -17,6 +20,7 @@ const (
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
 // precedences maps token types to their precedence
@@ -34,6 +38,9 @@ var precedences = map[TokenType]int{
 	DIVIDE:   PRODUCT,
 	MULTIPLY: PRODUCT,
 	LPAREN:   CALL,
+	DOT:      CALL,
+	QUESTION: CALL,
+	LBRACKET: INDEX,
 }
 
 // prefixParseFn represents a function that parses prefix expressions
@@ -46,7 +53,8 @@ type infixParseFn func(Expression) Expression
 type Parser struct {
 	l *Lexer
 
-	errors []string
+	errors    []string
+	positions []Token
 
 	curToken  Token
 	peekToken Token
@@ -65,12 +73,14 @@ func NewParser(l *Lexer) *Parser {
 	p.prefixParseFns = make(map[TokenType]prefixParseFn)
 	p.registerPrefix(IDENT, p.parseIdentifier)
 	p.registerPrefix(INT, p.parseIntegerLiteral)
+	p.registerPrefix(DECIMAL, p.parseDecimalLiteral)
 	p.registerPrefix(STRING, p.parseStringLiteral)
 	p.registerPrefix(TRUE, p.parseBooleanLiteral)
 	p.registerPrefix(FALSE, p.parseBooleanLiteral)
 	p.registerPrefix(NOT, p.parsePrefixExpression)
 	p.registerPrefix(MINUS, p.parsePrefixExpression)
 	p.registerPrefix(LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(SPAWN, p.parseSpawnExpression)
 
 	p.infixParseFns = make(map[TokenType]infixParseFn)
 	p.registerInfix(PLUS, p.parseInfixExpression)
@@ -86,6 +96,9 @@ func NewParser(l *Lexer) *Parser {
 	p.registerInfix(AND, p.parseInfixExpression)
 	p.registerInfix(OR, p.parseInfixExpression)
 	p.registerInfix(LPAREN, p.parseCallExpression)
+	p.registerInfix(DOT, p.parsePropertyExpression)
+	p.registerInfix(QUESTION, p.parsePropagateExpression)
+	p.registerInfix(LBRACKET, p.parseIndexExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -107,6 +120,9 @@ func (p *Parser) registerInfix(tokenType TokenType, fn infixParseFn) {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 // Errors returns the parsing errors
@@ -141,6 +157,10 @@ func (p *Parser) parseStatement() Statement {
 		return p.parseReturnStatement()
 	case IF:
 		return p.parseIfStatement()
+	case TRY:
+		return p.parseTryStatement()
+	case THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -171,9 +191,32 @@ func (p *Parser) parseLetStatement() *LetStatement {
 	return stmt
 }
 
+// docComment returns the text of the comment lines directly above tok,
+// with their "//" markers removed, or "" when there are none
+func (p *Parser) docComment(tok Token) string {
+	comments := p.l.Comments()
+	end := len(comments)
+	for end > 0 && comments[end-1].Line >= tok.Line {
+		end--
+	}
+
+	start, line := end, tok.Line
+	for start > 0 && comments[start-1].Line == line-1 && !comments[start-1].Trailing {
+		start--
+		line--
+	}
+
+	lines := []string{}
+	for _, c := range comments[start:end] {
+		text := strings.TrimPrefix(c.Text, "//")
+		lines = append(lines, strings.TrimPrefix(text, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
 // parseFunctionStatement parses function declarations
 func (p *Parser) parseFunctionStatement() *FunctionStatement {
-	stmt := &FunctionStatement{Token: p.curToken}
+	stmt := &FunctionStatement{Token: p.curToken, Doc: p.docComment(p.curToken)}
 
 	if !p.expectPeek(IDENT) {
 		return nil
@@ -185,7 +228,9 @@ func (p *Parser) parseFunctionStatement() *FunctionStatement {
 		return nil
 	}
 
-	stmt.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(stmt) {
+		return nil
+	}
 
 	if !p.expectPeek(LBRACE) {
 		return nil
@@ -196,32 +241,59 @@ func (p *Parser) parseFunctionStatement() *FunctionStatement {
 	return stmt
 }
 
-// parseFunctionParameters parses function parameter lists
-func (p *Parser) parseFunctionParameters() []*Identifier {
-	identifiers := []*Identifier{}
+// parseFunctionParameters parses function parameter lists, including
+// default values like greeting = "Hi" and a trailing rest parameter ...nums
+func (p *Parser) parseFunctionParameters(fs *FunctionStatement) bool {
+	fs.Parameters = []*Identifier{}
+	fs.Defaults = []Expression{}
 
 	if p.peekTokenIs(RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	hasDefault := false
+	for {
+		p.nextToken()
 
-	ident := &Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if p.curTokenIs(ELLIPSIS) {
+			if !p.expectPeek(IDENT) {
+				return false
+			}
+			fs.Rest = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			return p.expectPeek(RPAREN)
+		}
+
+		if !p.curTokenIs(IDENT) {
+			msg := fmt.Sprintf("expected parameter name, got %s instead", p.curToken.Type)
+			p.addError(p.curToken, msg)
+			return false
+		}
 
-	for p.peekTokenIs(COMMA) {
-		p.nextToken()
-		p.nextToken()
 		ident := &Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		var def Expression
+
+		if p.peekTokenIs(ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			hasDefault = true
+		} else if hasDefault {
+			msg := fmt.Sprintf("parameter %s without default follows parameter with default", ident.Value)
+			p.addError(ident.Token, msg)
+			return false
+		}
 
-	if !p.expectPeek(RPAREN) {
-		return nil
+		fs.Parameters = append(fs.Parameters, ident)
+		fs.Defaults = append(fs.Defaults, def)
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	return identifiers
+	return p.expectPeek(RPAREN)
 }
 
 // parseReturnStatement parses return statements
@@ -275,6 +347,70 @@ func (p *Parser) parseIfStatement() *IfStatement {
 	return stmt
 }
 
+// parseTryStatement parses try/catch/finally statements
+func (p *Parser) parseTryStatement() *TryStatement {
+	stmt := &TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+
+	stmt.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		stmt.CatchParam = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(LBRACE) {
+			return nil
+		}
+
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(LBRACE) {
+			return nil
+		}
+
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		msg := fmt.Sprintf("expected catch or finally after try block at line %d", stmt.Token.Line)
+		p.addError(stmt.Token, msg)
+		return nil
+	}
+
+	return stmt
+}
+
+// parseThrowStatement parses throw statements
+func (p *Parser) parseThrowStatement() *ThrowStatement {
+	stmt := &ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 // parseBlockStatement parses block statements
 func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Token: p.curToken}
@@ -290,6 +426,7 @@ func (p *Parser) parseBlockStatement() *BlockStatement {
 		p.nextToken()
 	}
 
+	block.RBrace = p.curToken
 	return block
 }
 
@@ -339,9 +476,15 @@ func (p *Parser) parseIntegerLiteral() Expression {
 	lit := &IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// Whether a literal may exceed 64 bits depends on the run
+		if lit.Big, _ = new(big.Int).SetString(p.curToken.Literal, 0); lit.Big != nil {
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -349,6 +492,16 @@ func (p *Parser) parseIntegerLiteral() Expression {
 	return lit
 }
 
+// parseDecimalLiteral parses decimal literals such as 12.50d
+func (p *Parser) parseDecimalLiteral() Expression {
+	value, ok := parseDecimal(strings.TrimSuffix(p.curToken.Literal, "d"))
+	if !ok {
+		p.addError(p.curToken, fmt.Sprintf("could not parse %q as decimal", p.curToken.Literal))
+		return nil
+	}
+	return &DecimalLiteral{Token: p.curToken, Value: value}
+}
+
 // parseStringLiteral parses string literals
 func (p *Parser) parseStringLiteral() Expression {
 	return &StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
@@ -370,6 +523,16 @@ func (p *Parser) parsePrefixExpression() Expression {
 
 	expression.Right = p.parseExpression(PREFIX)
 
+	// The smallest 64-bit integer is written as the negation of a
+	// literal that does not fit in 64 bits on its own
+	if lit, ok := expression.Right.(*IntegerLiteral); ok && expression.Operator == "-" && lit.Big != nil {
+		if value := new(big.Int).Neg(lit.Big); value.IsInt64() {
+			tok := expression.Token
+			tok.Type, tok.Literal, tok.Trailing = INT, "-"+lit.Token.Literal, lit.Token.Trailing
+			return &IntegerLiteral{Token: tok, Value: value.Int64()}
+		}
+	}
+
 	return expression
 }
 
@@ -404,35 +567,102 @@ func (p *Parser) parseGroupedExpression() Expression {
 // parseCallExpression parses function call expressions
 func (p *Parser) parseCallExpression(fn Expression) Expression {
 	exp := &CallExpression{Token: p.curToken, Function: fn}
-	exp.Arguments = p.parseExpressionList(RPAREN)
+	exp.Arguments = p.parseCallArguments()
 	return exp
 }
 
-// parseExpressionList parses a list of expressions separated by commas
-func (p *Parser) parseExpressionList(end TokenType) []Expression {
+// parseCallArguments parses the arguments of a call. Arguments may be
+// passed by name, like greet(name: "Bob"), after any positional ones.
+func (p *Parser) parseCallArguments() []Expression {
 	args := []Expression{}
 
-	if p.peekTokenIs(end) {
+	if p.peekTokenIs(RPAREN) {
 		p.nextToken()
 		return args
 	}
 
-	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
-
-	for p.peekTokenIs(COMMA) {
+	named := false
+	for {
 		p.nextToken()
+
+		if p.curTokenIs(IDENT) && p.peekTokenIs(COLON) {
+			arg := &NamedArgument{Token: p.curToken}
+			arg.Name = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+			arg.Value = p.parseExpression(LOWEST)
+			args = append(args, arg)
+			named = true
+		} else {
+			if named {
+				msg := fmt.Sprintf("positional argument follows named argument at line %d", p.curToken.Line)
+				p.addError(p.curToken, msg)
+				return nil
+			}
+			args = append(args, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(end) {
+	if !p.expectPeek(RPAREN) {
 		return nil
 	}
 
 	return args
 }
 
+// parseIndexExpression parses index expressions like nums[0]
+func (p *Parser) parseIndexExpression(left Expression) Expression {
+	exp := &IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parsePropertyExpression parses property access expressions like err.kind
+func (p *Parser) parsePropertyExpression(object Expression) Expression {
+	exp := &PropertyExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+
+	exp.Property = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parsePropagateExpression parses the postfix error propagation operator
+func (p *Parser) parsePropagateExpression(value Expression) Expression {
+	return &PropagateExpression{Token: p.curToken, Value: value}
+}
+
+// parseSpawnExpression parses spawn expressions like spawn worker(1, 2)
+func (p *Parser) parseSpawnExpression() Expression {
+	expression := &SpawnExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	call, ok := p.parseExpression(PREFIX).(*CallExpression)
+	if !ok {
+		msg := fmt.Sprintf("expected function call after spawn at line %d", expression.Token.Line)
+		p.addError(expression.Token, msg)
+		return nil
+	}
+
+	expression.Call = call
+	return expression
+}
+
 // Helper functions
 
 // curTokenIs checks if current token is of given type
@@ -474,15 +704,39 @@ func (p *Parser) curPrecedence() int {
 
 // Error handling functions
 
+// addError records a parse error found at tok
+func (p *Parser) addError(tok Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.positions = append(p.positions, tok)
+}
+
+// Diagnostics returns the parse errors with their source positions
+func (p *Parser) Diagnostics() []Diagnostic {
+	diagnostics := make([]Diagnostic, len(p.errors))
+	for i, msg := range p.errors {
+		diagnostics[i] = Diagnostic{
+			Line:    p.positions[i].Line,
+			Column:  p.positions[i].Column,
+			Rule:    "syntax",
+			Message: msg,
+		}
+	}
+	return diagnostics
+}
+
 // peekError adds a peek error to the errors slice
 func (p *Parser) peekError(t TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 // noPrefixParseFnError adds a no prefix parse function error
 func (p *Parser) noPrefixParseFnError(t TokenType) {
+	if lit := p.curToken.Literal; t == ILLEGAL && lit != "" && isDigit(lit[0]) {
+		p.addError(p.curToken, fmt.Sprintf("%s is not a number: decimals need a d suffix, as in %sd", lit, lit))
+		return
+	}
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
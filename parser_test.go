@@ -247,6 +247,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a));",
 		},
+		{
+			"-9223372036854775808 * b",
+			"(-9223372036854775808 * b);",
+		},
+		{
+			"-9223372036854775809 * b",
+			"((-9223372036854775809) * b);",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c);",
@@ -544,6 +552,135 @@ func TestStringLiteralExpression(t *testing.T) {
 
 // Helper functions
 
+func TestTryStatementParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { risky(); } catch (e) { e.message; }`, "try { risky(); } catch (e) { e.message; }"},
+		{`try { risky(); } finally { cleanup(); }`, "try { risky(); } finally { cleanup(); }"},
+		{`try { a; } catch (err) { b; } finally { c; }`, "try { a; } catch (err) { b; } finally { c; }"},
+		{`throw "bad" + input;`, `throw ("bad" + input);`},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{`try { a; }`, `try { a; } catch e { b; }`, `try { a; } catch () { b; }`} {
+		p := NewParser(New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parse errors for %q", input)
+		}
+	}
+}
+
+func TestPropagateExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"int(x)?;", "int(x)?;"},
+		{"a? + b?;", "(a? + b?);"},
+		{"!check(x)?;", "(!check(x)?);"},
+		{"parse(s)?.value;", "parse(s)?.value;"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`func greet(name, greeting = "Hi") { }`, `func greet(name, greeting = "Hi") { }`},
+		{`func sum(...nums) { }`, `func sum(...nums) { }`},
+		{`func f(a, b = a * 2, ...rest) { }`, `func f(a, b = (a * 2), ...rest) { }`},
+		{`greet(name: "Bob");`, `greet(name: "Bob");`},
+		{`greet("Bob", greeting: "Yo");`, `greet("Bob", greeting: "Yo");`},
+		{`nums[2 + 3];`, `(nums[(2 + 3)]);`},
+		{`a * f(1)[b * c];`, `(a * (f(1)[(b * c)]));`},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{
+		`func f(a = 1, b) { }`,
+		`func f(...rest, a) { }`,
+		`func f(1) { }`,
+		`f(name: 1, 2);`,
+	} {
+		p := NewParser(New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parse errors for %q", input)
+		}
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `// not attached
+
+// Adds two numbers.
+//   Second line keeps its indent.
+func add(a, b) { return a + b; }
+let x = 1; // trailing, not a doc comment
+func first(a) { }
+// separated
+
+func second() { }`
+
+	expected := []string{"Adds two numbers.\n  Second line keeps its indent.", "", ""}
+
+	for _, l := range []*Lexer{New(input), NewWithTrivia(input)} {
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var docs []string
+		for _, stmt := range program.Statements {
+			if fs, ok := stmt.(*FunctionStatement); ok {
+				docs = append(docs, fs.Doc)
+			}
+		}
+		if len(docs) != len(expected) {
+			t.Fatalf("expected %d functions, got %d", len(expected), len(docs))
+		}
+		for i, want := range expected {
+			if docs[i] != want {
+				t.Errorf("function %d: expected doc %q, got %q", i, want, docs[i])
+			}
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s Statement, name string) bool {
 	if s.String()[:3] != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.String()[:3])
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NewPrelude evaluates source into a fresh environment and freezes it.
+// The result is immutable and can be shared by any number of concurrent
+// runs, each layering its own scope on top with NewRunEnvironment.
+func NewPrelude(source string) (*Environment, error) {
+	lexer := New(source)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+
+	if len(parser.Errors()) > 0 {
+		return nil, fmt.Errorf("prelude parse errors: %s", strings.Join(parser.Errors(), "; "))
+	}
+
+	env := NewEnvironment()
+	if result := Eval(program, env); isError(result) {
+		return nil, fmt.Errorf("prelude: %s", result.Inspect())
+	}
+
+	return env.Freeze(), nil
+}
+
+// NewRunEnvironment creates the top-level environment for a single run.
+// Definitions made by the run stay in the returned scope; the prelude,
+// which may be nil, is only ever read.
+func NewRunEnvironment(prelude *Environment) *Environment {
+	if prelude == nil {
+		return NewEnvironment()
+	}
+	env := NewEnclosedEnvironment(prelude)
+	env.rt = newRuntime()
+	return env
+}
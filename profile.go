@@ -0,0 +1,326 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// profileFunction identifies a profiled function by name and the line it
+// is defined on; builtins have line 0
+type profileFunction struct {
+	Name string
+	Line int
+}
+
+// profileCall is a call in progress
+type profileCall struct {
+	fn        int // index into Profiler.functions
+	start     time.Time
+	childTime time.Duration
+}
+
+// profileSample accumulates the calls that ended with the same stack
+type profileSample struct {
+	stack []int // function indexes, outermost first
+	calls int64
+	self  time.Duration
+}
+
+// FunctionProfile summarizes the calls of one function
+type FunctionProfile struct {
+	Name  string
+	Line  int
+	Calls int64
+	Self  time.Duration
+	Total time.Duration // counting recursive calls once
+}
+
+// Profiler records how often each function is called and how long its
+// calls take, per call stack. Time spent in top-level code is charged to
+// "top-level".
+type Profiler struct {
+	BaseHooks
+
+	filename string
+
+	mu        sync.Mutex
+	once      sync.Once
+	begin     time.Time
+	functions []profileFunction
+	index     map[profileFunction]int
+	stacks    map[int][]*profileCall // calls in progress by task ID
+	samples   map[string]*profileSample
+	order     []*profileSample
+	duration  time.Duration
+}
+
+// NewProfiler creates a profiler for the program in filename
+func NewProfiler(filename string) *Profiler {
+	return &Profiler{
+		filename: filename,
+		index:    map[profileFunction]int{},
+		stacks:   map[int][]*profileCall{},
+		samples:  map[string]*profileSample{},
+	}
+}
+
+// OnStatement implements EvalHooks. The first statement starts the clock.
+func (p *Profiler) OnStatement(stmt Statement, pos Token, env *Environment) Object {
+	p.once.Do(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.begin = time.Now()
+		p.stacks[0] = []*profileCall{p.newCall(profileFunction{Name: "top-level"})}
+	})
+	return nil
+}
+
+// OnCall implements EvalHooks
+func (p *Profiler) OnCall(node Node, fn Object, args []Object, pos Token, env *Environment) {
+	key := profileFunction{Name: traceName(fn)}
+	if f, ok := fn.(*Function); ok {
+		key.Line = f.Body.Token.Line
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	task := profileTask(env)
+	p.stacks[task] = append(p.stacks[task], p.newCall(key))
+}
+
+// OnReturn implements EvalHooks
+func (p *Profiler) OnReturn(node Node, fn Object, result Object, pos Token, env *Environment) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finishCall(profileTask(env))
+}
+
+// Stop charges the time of the top-level code; call it once the program
+// has finished
+func (p *Profiler) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.stacks[0]) == 1 {
+		p.finishCall(0)
+		p.duration = time.Since(p.begin)
+	}
+}
+
+// newCall starts a call of the function key
+func (p *Profiler) newCall(key profileFunction) *profileCall {
+	fn, ok := p.index[key]
+	if !ok {
+		fn = len(p.functions)
+		p.functions = append(p.functions, key)
+		p.index[key] = fn
+	}
+	return &profileCall{fn: fn, start: time.Now()}
+}
+
+// finishCall ends the innermost call of a task and adds it to the sample
+// for its stack
+func (p *Profiler) finishCall(task int) {
+	stack := p.stacks[task]
+	if len(stack) == 0 {
+		return
+	}
+	call := stack[len(stack)-1]
+	elapsed := time.Since(call.start)
+	p.stacks[task] = stack[:len(stack)-1]
+
+	if len(stack) > 1 {
+		parent := stack[len(stack)-2]
+		parent.childTime += elapsed
+	}
+
+	fns := make([]int, len(stack))
+	keyParts := make([]string, len(stack))
+	for i, c := range stack {
+		fns[i] = c.fn
+		keyParts[i] = strconv.Itoa(c.fn)
+	}
+	key := strings.Join(keyParts, ",")
+	sample, ok := p.samples[key]
+	if !ok {
+		sample = &profileSample{stack: fns}
+		p.samples[key] = sample
+		p.order = append(p.order, sample)
+	}
+	sample.calls++
+	sample.self += elapsed - call.childTime
+}
+
+// profileTask returns the task a call site runs in, 0 for the main program
+func profileTask(env *Environment) int {
+	if env.frame == nil {
+		return 0
+	}
+	return env.frame.TaskID
+}
+
+// Functions summarizes the profile per function, ordered by total time
+func (p *Profiler) Functions() []FunctionProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]FunctionProfile, len(p.functions))
+	for i, fn := range p.functions {
+		result[i] = FunctionProfile{Name: fn.Name, Line: fn.Line}
+	}
+	for _, sample := range p.order {
+		leaf := sample.stack[len(sample.stack)-1]
+		result[leaf].Calls += sample.calls
+		result[leaf].Self += sample.self
+
+		seen := map[int]bool{}
+		for _, fn := range sample.stack {
+			if !seen[fn] {
+				seen[fn] = true
+				result[fn].Total += sample.self
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Total > result[j].Total })
+	return result
+}
+
+// Write writes the profile in the gzipped protocol buffer format read by
+// "go tool pprof"
+func (p *Profiler) Write(w io.Writer) error {
+	p.mu.Lock()
+	data := p.encode()
+	p.mu.Unlock()
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// encode builds the profile.proto message
+func (p *Profiler) encode() []byte {
+	strs := []string{""}
+	strIndex := map[string]int{"": 0}
+	str := func(s string) uint64 {
+		i, ok := strIndex[s]
+		if !ok {
+			i = len(strs)
+			strs = append(strs, s)
+			strIndex[s] = i
+		}
+		return uint64(i)
+	}
+	valueType := func(typ, unit string) func(m *protoWriter) {
+		return func(m *protoWriter) {
+			m.uint64Field(1, str(typ))
+			m.uint64Field(2, str(unit))
+		}
+	}
+
+	var out protoWriter
+	out.message(1, valueType("calls", "count"))
+	out.message(1, valueType("time", "nanoseconds"))
+
+	for _, sample := range p.order {
+		locations := make([]uint64, len(sample.stack))
+		for i, fn := range sample.stack {
+			// Locations are listed innermost first
+			locations[len(sample.stack)-1-i] = uint64(fn + 1)
+		}
+		values := []uint64{uint64(sample.calls), uint64(sample.self)}
+		out.message(2, func(m *protoWriter) {
+			m.packed(1, locations)
+			m.packed(2, values)
+		})
+	}
+
+	// One location per function, at the line it is defined on
+	for i, fn := range p.functions {
+		id := uint64(i + 1)
+		line := uint64(fn.Line)
+		out.message(4, func(m *protoWriter) {
+			m.uint64Field(1, id)
+			m.message(4, func(l *protoWriter) {
+				l.uint64Field(1, id)
+				l.uint64Field(2, line)
+			})
+		})
+	}
+	for i, fn := range p.functions {
+		id := uint64(i + 1)
+		name, filename, line := str(fn.Name), str(p.filename), uint64(fn.Line)
+		out.message(5, func(m *protoWriter) {
+			m.uint64Field(1, id)
+			m.uint64Field(2, name)
+			m.uint64Field(3, name)
+			m.uint64Field(4, filename)
+			m.uint64Field(5, line)
+		})
+	}
+
+	timeType := valueType("time", "nanoseconds")
+	defaultType := str("time")
+	for _, s := range strs {
+		out.bytesField(6, []byte(s))
+	}
+	if !p.begin.IsZero() {
+		out.uint64Field(9, uint64(p.begin.UnixNano()))
+	}
+	out.uint64Field(10, uint64(p.duration))
+	out.message(11, timeType)
+	out.uint64Field(12, 1)
+	out.uint64Field(14, defaultType)
+	return out.buf
+}
+
+// protoWriter encodes protocol buffer fields
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) varint(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+// uint64Field writes a varint field, omitting the default value 0
+func (w *protoWriter) uint64Field(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.varint(uint64(field) << 3)
+	w.varint(v)
+}
+
+// bytesField writes a length-delimited field
+func (w *protoWriter) bytesField(field int, b []byte) {
+	w.varint(uint64(field)<<3 | 2)
+	w.varint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// packed writes a packed repeated varint field
+func (w *protoWriter) packed(field int, values []uint64) {
+	var inner protoWriter
+	for _, v := range values {
+		inner.varint(v)
+	}
+	w.bytesField(field, inner.buf)
+}
+
+// message writes an embedded message built by fn
+func (w *protoWriter) message(field int, fn func(m *protoWriter)) {
+	var inner protoWriter
+	fn(&inner)
+	w.bytesField(field, inner.buf)
+}
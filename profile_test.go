@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const profileTestProgram = `func fib(n) {
+    if (n < 2) {
+        return n;
+    }
+    return fib(n - 1) + fib(n - 2);
+}
+func run() {
+    return fib(10) + len("ab");
+}
+run();`
+
+func profileProgram(t *testing.T, input string) *Profiler {
+	t.Helper()
+	profiler := NewProfiler("test.tiny")
+	if result := runWithHooks(input, profiler); isError(result) {
+		t.Fatalf("program failed: %s", result.Inspect())
+	}
+	profiler.Stop()
+	return profiler
+}
+
+func TestProfilerFunctions(t *testing.T) {
+	functions := map[string]FunctionProfile{}
+	for _, fn := range profileProgram(t, profileTestProgram).Functions() {
+		functions[fn.Name] = fn
+	}
+
+	expected := []struct {
+		name  string
+		line  int
+		calls int64
+	}{
+		{"top-level", 0, 1},
+		{"run", 7, 1},
+		{"fib", 1, 177},
+		{"len", 0, 1},
+	}
+	for _, tt := range expected {
+		fn, ok := functions[tt.name]
+		if !ok {
+			t.Errorf("no profile for %s", tt.name)
+			continue
+		}
+		if fn.Line != tt.line || fn.Calls != tt.calls {
+			t.Errorf("%s: line %d, %d calls; want line %d, %d calls", tt.name, fn.Line, fn.Calls, tt.line, tt.calls)
+		}
+		if fn.Self < 0 || fn.Total < fn.Self {
+			t.Errorf("%s: inconsistent times self=%v total=%v", tt.name, fn.Self, fn.Total)
+		}
+	}
+
+	// Recursive calls are counted once in the total time
+	if fib, run := functions["fib"], functions["run"]; fib.Total > run.Total {
+		t.Errorf("fib total %v exceeds its caller's %v", fib.Total, run.Total)
+	}
+	if run, top := functions["run"], functions["top-level"]; run.Total > top.Total {
+		t.Errorf("run total %v exceeds top-level %v", run.Total, top.Total)
+	}
+}
+
+// protoFields decodes the top-level fields of a protocol buffer message,
+// keeping length-delimited ones
+func protoFields(t *testing.T, data []byte) map[int][][]byte {
+	t.Helper()
+	fields := map[int][][]byte{}
+	varint := func() uint64 {
+		var v uint64
+		for shift := 0; ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatal("truncated varint")
+			}
+			b := data[0]
+			data = data[1:]
+			v |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return v
+			}
+		}
+	}
+	for len(data) > 0 {
+		key := varint()
+		switch key & 7 {
+		case 0:
+			varint()
+		case 2:
+			n := varint()
+			fields[int(key>>3)] = append(fields[int(key>>3)], data[:n])
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func TestProfilerWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := profileProgram(t, profileTestProgram).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := protoFields(t, data)
+	if len(fields[1]) != 2 {
+		t.Errorf("expected 2 sample types, got %d", len(fields[1]))
+	}
+	// Samples: top-level, run, len, and fib at each recursion depth
+	if len(fields[2]) != 13 {
+		t.Errorf("expected 13 samples, got %d", len(fields[2]))
+	}
+	if len(fields[4]) != 4 || len(fields[5]) != 4 {
+		t.Errorf("expected 4 locations and functions, got %d and %d", len(fields[4]), len(fields[5]))
+	}
+
+	strs := map[string]bool{}
+	for i, s := range fields[6] {
+		if i == 0 && len(s) != 0 {
+			t.Errorf("string table must start with the empty string")
+		}
+		strs[string(s)] = true
+	}
+	for _, want := range []string{"calls", "time", "nanoseconds", "fib", "run", "top-level", "test.tiny"} {
+		if !strs[want] {
+			t.Errorf("string table is missing %q", want)
+		}
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prog.tiny")
+	if err := os.WriteFile(file, []byte(profileTestProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.pprof")
+
+	var stdout, stderr bytes.Buffer
+	if code := runRun([]string{"--profile", out, file}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if stdout.String() != "57\n" {
+		t.Errorf("wrong output: %q", stdout.String())
+	}
+	info, err := os.Stat(out)
+	if err != nil || info.Size() == 0 {
+		t.Errorf("profile not written: %v", err)
+	}
+}
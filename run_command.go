@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// limitFlags registers the -max-steps, -max-depth and -timeout flags
+func limitFlags(flags *flag.FlagSet) *Limits {
+	limits := &Limits{}
+	flags.Int64Var(&limits.MaxSteps, "max-steps", 0, "stop after `n` statements (0 means no limit)")
+	flags.IntVar(&limits.MaxDepth, "max-depth", 0, "stop when calls nest deeper than `n` (0 means no limit)")
+	flags.DurationVar(&limits.Timeout, "timeout", 0, "stop after `duration`, such as 5s (0 means no limit)")
+	return limits
+}
+
+// overflowFlag registers the -overflow flag
+func overflowFlag(flags *flag.FlagSet) *OverflowMode {
+	mode := new(OverflowMode)
+	flags.Var(mode, "overflow", "`mode` of integer arithmetic past 64 bits: error raises an OverflowError, bigint switches to arbitrary precision")
+	return mode
+}
+
+// roundingFlag registers the -rounding flag
+func roundingFlag(flags *flag.FlagSet) *RoundingMode {
+	mode := new(RoundingMode)
+	flags.Var(mode, "rounding", "`mode` of decimal division: half-even, half-up or down")
+	return mode
+}
+
+// runRun implements "tinylang run [flags] file.tiny [args...]". The file
+// "-" reads the program from stdin, and the arguments after the file are
+// passed to the script as the args array. It returns the process exit code.
+func runRun(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	astInput := flags.Bool("ast", false, "read the program as a JSON AST written by \"tinylang ast -format json\"")
+	trace := flags.Bool("trace", false, "print every call with its arguments and result to stderr")
+	profilePath := flags.String("profile", "", "write a pprof profile of function calls to `file`")
+	cover := flags.Bool("cover", false, "print statement and branch coverage to stderr")
+	coverProfile := flags.String("coverprofile", "", "write an LCOV coverage report to `file`; implies -cover")
+	coverHTML := flags.String("coverhtml", "", "write an annotated HTML coverage view to `file`; implies -cover")
+	noOpt := flags.Bool("no-opt", false, "run the program without constant folding; implied by coverage")
+	overflow := overflowFlag(flags)
+	rounding := roundingFlag(flags)
+	limits := limitFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tinylang run [flags] <file.tiny | -> [args...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	filename := flags.Arg(0)
+
+	cfg := &runConfig{Args: flags.Args()[1:], Stdout: stdout, Stderr: stderr, Hooks: limits.hooks(), NoOpt: *noOpt, Overflow: *overflow, Rounding: *rounding}
+	if *trace {
+		cfg.Hooks = append(cfg.Hooks, NewTracer(stderr))
+	}
+	var profiler *Profiler
+	if *profilePath != "" {
+		profiler = NewProfiler(filename)
+		cfg.Hooks = append(cfg.Hooks, profiler)
+	}
+	var coverage *Coverage
+	if *cover || *coverProfile != "" || *coverHTML != "" {
+		coverage = NewCoverage()
+		cfg.Hooks = append(cfg.Hooks, coverage)
+		// coverage is measured on the program as written
+		cfg.NoOpt = true
+	}
+
+	name, content, err := readSource(filename, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "run: %v\n", err)
+		return exitUsage
+	}
+	var status int
+	if *astInput {
+		program, err := LoadProgramJSON(content)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			return exitParseError
+		}
+		status = runProgram(name, "", program, cfg)
+	} else {
+		status = runSource(name, string(content), cfg)
+	}
+
+	if profiler != nil {
+		profiler.Stop()
+		if err := writeFileWith(*profilePath, profiler.Write); err != nil {
+			fmt.Fprintf(stderr, "run: %v\n", err)
+			return exitUsage
+		}
+	}
+	if coverage != nil {
+		fmt.Fprintln(stderr, coverage.Summary())
+		if err := writeCoverage(coverage, *coverProfile, *coverHTML); err != nil {
+			fmt.Fprintf(stderr, "run: %v\n", err)
+			return exitUsage
+		}
+	}
+	return status
+}
+
+// runEval implements "tinylang eval -e source [args...]", which runs a
+// program given on the command line and prints its value
+func runEval(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	source := flags.String("e", "", "the `program` to evaluate")
+	noOpt := flags.Bool("no-opt", false, "run the program without constant folding")
+	overflow := overflowFlag(flags)
+	rounding := roundingFlag(flags)
+	limits := limitFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tinylang eval [-no-opt] [-overflow mode] [-rounding mode] [-max-steps n] [-max-depth n] [-timeout duration] -e <program> [args...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *source == "" {
+		flags.Usage()
+		return exitUsage
+	}
+	return runSource("<eval>", *source, &runConfig{Args: flags.Args(), Stdout: stdout, Stderr: stderr, Hooks: limits.hooks(), NoOpt: *noOpt, Overflow: *overflow, Rounding: *rounding})
+}
+
+// writeCoverage saves the LCOV and HTML reports whose paths are set
+func writeCoverage(coverage *Coverage, lcovPath, htmlPath string) error {
+	if lcovPath != "" {
+		if err := writeFileWith(lcovPath, coverage.WriteLCOV); err != nil {
+			return err
+		}
+	}
+	if htmlPath != "" {
+		return writeFileWith(htmlPath, coverage.WriteHTML)
+	}
+	return nil
+}
+
+// writeFileWith creates path and fills it with write
+func writeFileWith(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
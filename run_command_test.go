@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-"}, "print(\"hi\"); 1 + 1;", exitOK, "hi\n2\n", ""},
+		{[]string{"-", "a", "-b"}, "args;", exitOK, "[a, -b]\n", ""},
+		{[]string{"-"}, "let = 1;", exitParseError, "", "<stdin>:1:5: expected next token to be IDENT, got = instead\n<stdin>:1:5: no prefix parse function for = found\n"},
+		{[]string{"-"}, "let n = 1;\nn + \"a\";", exitRuntimeError, "", "<stdin>:2:3: TypeError: type mismatch: INTEGER + STRING\n"},
+		{[]string{"-"}, "\n1 + \"a\";", exitParseError, "", "<stdin>:2:3: TypeError: type mismatch: INTEGER + STRING\n"},
+		{[]string{"-no-opt", "-"}, "\n1 + \"a\";", exitRuntimeError, "", "<stdin>:2:3: TypeError: type mismatch: INTEGER + STRING\n"},
+		{[]string{"-"}, "try { 1 / 0; } catch (e) { e.kind; }", exitOK, "ZeroDivisionError\n", ""},
+		{[]string{"-"}, "if (false) { 1 / 0; } 3", exitOK, "3\n", ""},
+		{[]string{"-"}, "func f() { return 1 / 0; } 5", exitOK, "5\n", ""},
+		{[]string{"-"}, "let r = int(\"x\")?;\n5", exitRuntimeError, "", "<stdin>:1:12: ValueError: could not parse \"x\" as integer\n"},
+		{[]string{"-max-steps", "10", "-"}, "func f(n) { return f(n + 1); } f(0);", exitLimitExceeded, "",
+			"<stdin>:1:13: LimitError: step limit of 10 exceeded\n"},
+		{[]string{"-max-depth", "5", "-"}, "func f(n) { try { return f(n + 1); } catch (e) { return 0; } } f(0);", exitLimitExceeded, "",
+			"<stdin>:1:13: LimitError: call depth limit of 5 exceeded\n"},
+		{[]string{"-max-depth", "5", "-"}, "func f(n) { if (n == 5) { return n; } return f(n + 1); } f(1);", exitOK, "5\n", ""},
+		{[]string{"-timeout", "20ms", "-"}, "func f(n) { return f(n + 1); } f(0);", exitLimitExceeded, "",
+			"<stdin>:1:13: LimitError: time limit of 20ms exceeded\n"},
+		{[]string{"missing.tiny"}, "", exitUsage, "", "run: open missing.tiny: no such file or directory\n"},
+		{[]string{}, "", exitUsage, "", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runRun(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v %q: expected exit code %d, got %d (%s)", tt.args, tt.stdin, tt.code, code, stderr.String())
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("%v %q: wrong stdout %q, want %q", tt.args, tt.stdin, stdout.String(), tt.stdout)
+		}
+		if tt.stderr != "" && stderr.String() != tt.stderr {
+			t.Errorf("%v %q: wrong stderr %q, want %q", tt.args, tt.stdin, stderr.String(), tt.stderr)
+		}
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		output string
+	}{
+		{[]string{"-e", "str(6 * 7) + args[0]", "!"}, exitOK, "42!\n"},
+		{[]string{"-e", "1 / 0"}, exitParseError, "<eval>:1:3: ZeroDivisionError: division by zero\n"},
+		{[]string{"-no-opt", "-e", "1 / 0"}, exitRuntimeError, "<eval>:1:3: ZeroDivisionError: division by zero\n"},
+		{[]string{"-e", "(1"}, exitParseError, "<eval>:1:3: expected next token to be ), got EOF instead\n"},
+		{[]string{"-max-steps", "3", "-e", "func f() { return f(); } f();"}, exitLimitExceeded, "<eval>:1:12: LimitError: step limit of 3 exceeded\n"},
+		{[]string{"-e", "9223372036854775807 + 1"}, exitRuntimeError, "<eval>:1:21: OverflowError: integer overflow: 9223372036854775807 + 1\n"},
+		{[]string{"-overflow", "bigint", "-e", "9223372036854775807 + 1"}, exitOK, "9223372036854775808\n"},
+		{[]string{"-overflow", "wrap", "-e", "1"}, exitUsage, ""},
+		{[]string{"-e", "5.00d / 8"}, exitOK, "0.62\n"},
+		{[]string{"-rounding", "half-up", "-e", "5.00d / 8"}, exitOK, "0.63\n"},
+		{[]string{"-rounding", "up", "-e", "1"}, exitUsage, ""},
+		{[]string{}, exitUsage, ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runEval(tt.args, &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v: expected exit code %d, got %d (%s)", tt.args, tt.code, code, stderr.String())
+		}
+		output := stdout.String()
+		if code != exitOK {
+			output = stderr.String()
+		}
+		if tt.output != "" && output != tt.output {
+			t.Errorf("%v: wrong output %q, want %q", tt.args, output, tt.output)
+		}
+	}
+}
@@ -2,37 +2,130 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
-// RunFile executes a TinyLang file
-func RunFile(filename string) {
+// Exit codes of every command, so that scripts and CI pipelines can tell
+// why a program failed
+const (
+	exitOK            = 0
+	exitRuntimeError  = 1 // also failed tests and problems found by check and lint
+	exitUsage         = 2 // bad flags or a file that cannot be read or written
+	exitParseError    = 3 // also errors the optimizer finds before running
+	exitLimitExceeded = 4
+)
+
+// stdinName is the name reported for programs read from standard input
+const stdinName = "<stdin>"
+
+// RunFile executes a TinyLang file with the given hooks installed and
+// returns the exit code
+func RunFile(filename string, hooks ...EvalHooks) int {
+	return runFile(filename, &runConfig{Stdout: os.Stdout, Stderr: os.Stderr, Hooks: hooks})
+}
+
+// runConfig holds what a run needs besides the program itself
+type runConfig struct {
+	Args   []string // bound to the args global
+	Hooks  []EvalHooks
+	Stdout io.Writer // the program's output and its final value
+	Stderr io.Writer // diagnostics
+
+	// NoOpt runs the program as parsed, without the optimizer
+	NoOpt bool
+
+	// Overflow says what integer arithmetic does past 64 bits
+	Overflow OverflowMode
+
+	// Rounding says how decimal divisions round
+	Rounding RoundingMode
+}
+
+// readSource reads a program from a file, or from stdin when path is "-",
+// and returns it with the name to report it under
+func readSource(path string, stdin io.Reader) (name string, content []byte, err error) {
+	if path == "-" {
+		content, err = io.ReadAll(stdin)
+		return stdinName, content, err
+	}
+	content, err = os.ReadFile(path)
+	return path, content, err
+}
+
+// runFile executes a TinyLang file
+func runFile(filename string, cfg *runConfig) int {
 	content, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
-		return
+		fmt.Fprintf(cfg.Stderr, "Error reading file: %v\n", err)
+		return exitUsage
 	}
+	return runSource(filename, string(content), cfg)
+}
 
-	code := string(content)
-	lexer := New(code)
-	parser := NewParser(lexer)
+// runSource parses and executes a program. Parse errors are reported on
+// stderr as "name:line:column: message".
+func runSource(name, code string, cfg *runConfig) int {
+	parser := NewParser(New(code))
 	program := parser.ParseProgram()
 
-	if len(parser.Errors()) > 0 {
-		fmt.Println("Parse errors:")
-		for _, err := range parser.Errors() {
-			fmt.Printf("  - %s\n", err)
+	if diagnostics := parser.Diagnostics(); len(diagnostics) > 0 {
+		for _, d := range diagnostics {
+			fmt.Fprintf(cfg.Stderr, "%s:%d:%d: %s\n", name, d.Line, d.Column, d.Message)
+		}
+		return exitParseError
+	}
+	return runProgram(name, code, program, cfg)
+}
+
+// runProgram optimizes and evaluates a parsed program. The final value
+// goes to stdout and errors to stderr, with an exit code telling them
+// apart.
+func runProgram(name, code string, program *Program, cfg *runConfig) int {
+	if !cfg.NoOpt {
+		if errors := Optimize(program); len(errors) > 0 {
+			for _, err := range errors {
+				fmt.Fprintln(cfg.Stderr, runtimeErrorMessage(name, err))
+			}
+			return exitParseError
 		}
-		return
 	}
 
 	env := NewEnvironment()
+	env.rt.SetOutput(cfg.Stdout)
+	env.rt.SetOverflow(cfg.Overflow)
+	env.rt.SetRounding(cfg.Rounding)
+	args := &Array{Elements: make([]Object, len(cfg.Args))}
+	for i, arg := range cfg.Args {
+		args.Elements[i] = &String{Value: arg}
+	}
+	env.Set("args", args)
+	for _, h := range cfg.Hooks {
+		if coverage, ok := h.(*Coverage); ok {
+			coverage.AddFile(name, code, program)
+		}
+		env.AddHooks(h)
+	}
 	result := Eval(program, env)
 
-	if result.Type() == ERROR_OBJ {
-		fmt.Printf("Runtime Error: %s\n", result.Inspect())
-		return
+	if err, ok := result.(*Error); ok {
+		fmt.Fprintln(cfg.Stderr, runtimeErrorMessage(name, err))
+		if err.Kind == LIMIT_ERROR {
+			return exitLimitExceeded
+		}
+		return exitRuntimeError
+	}
+
+	if result != nil {
+		fmt.Fprintln(cfg.Stdout, result.Inspect())
 	}
+	return exitOK
+}
 
-	fmt.Println(result.Inspect())
+// runtimeErrorMessage describes an uncaught error with its position
+func runtimeErrorMessage(name string, err *Error) string {
+	if err.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", name, err.Line, err.Column, err.Kind, err.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", name, err.Kind, err.Message)
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Runtime holds the state shared by every scope of a single run. Each
+// top-level environment gets its own Runtime, and function calls carry
+// the caller's Runtime into the callee's scope.
+type Runtime struct {
+	mu sync.Mutex
+
+	// running counts the goroutines of this run that are able to make
+	// progress; blocked holds the waiters of those that are not.
+	running int
+	blocked map[*waiter]struct{}
+
+	nextTaskID    int
+	nextChannelID int
+
+	// hooks, when set, observe the evaluation
+	hooks EvalHooks
+
+	// overflow says what integer arithmetic does past 64 bits
+	overflow OverflowMode
+
+	// rounding says how decimal divisions round
+	rounding RoundingMode
+
+	// keepFrames makes tail calls ordinary calls, which grow the stack
+	keepFrames bool
+
+	// out receives the program's output; nil means standard output
+	out io.Writer
+}
+
+// Frame is an active function call. The environment of a call points at
+// its frame, so the call stack can be recovered from any scope.
+type Frame struct {
+	Function *Function // nil for the root frame of a spawned task
+	Caller   *Frame
+	Env      *Environment
+	Depth    int
+	TaskID   int // the task running the call, 0 for the main program
+
+	// Line is the line of the statement the frame is executing; it is
+	// only kept up to date while a debugger is attached
+	Line int
+}
+
+// newFrame returns the frame for a call made from caller
+func newFrame(fn *Function, caller *Frame, env *Environment) Frame {
+	frame := Frame{Function: fn, Caller: caller, Env: env, Depth: 1}
+	if caller != nil {
+		frame.Depth = caller.Depth + 1
+		frame.TaskID = caller.TaskID
+	}
+	return frame
+}
+
+// Name returns the function name shown for the frame in backtraces
+func (f *Frame) Name() string {
+	if f.Function == nil {
+		return fmt.Sprintf("<task %d>", f.TaskID)
+	}
+	return f.Function.Name
+}
+
+// newRuntime creates the state for a fresh run
+func newRuntime() *Runtime {
+	return &Runtime{blocked: make(map[*waiter]struct{})}
+}
+
+// SetOutput redirects what the program prints to w
+func (rt *Runtime) SetOutput(w io.Writer) {
+	rt.out = w
+}
+
+// Output returns the writer the program prints to
+func (rt *Runtime) Output() io.Writer {
+	if rt.out == nil {
+		return os.Stdout
+	}
+	return rt.out
+}
+
+// enter records that a goroutine started evaluating code in this run
+func (rt *Runtime) enter() {
+	rt.mu.Lock()
+	rt.running++
+	rt.mu.Unlock()
+}
+
+// exit records that a goroutine finished evaluating code in this run
+func (rt *Runtime) exit() {
+	rt.mu.Lock()
+	rt.running--
+	rt.detectDeadlock()
+	rt.mu.Unlock()
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// testResult is the outcome of one test function
+type testResult struct {
+	Name    string
+	Elapsed time.Duration
+	Failure *Error // nil when the test passed
+}
+
+// testFileResult holds the outcomes of the tests in one file
+type testFileResult struct {
+	Path    string
+	Tests   []testResult
+	Elapsed time.Duration
+
+	// ParseErrors is set when the file could not be parsed
+	ParseErrors []string
+}
+
+// failed counts the failed tests of the file
+func (r testFileResult) failed() int {
+	n := 0
+	for _, t := range r.Tests {
+		if t.Failure != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// runTest implements "tinylang test [flags] [file | dir ...]". It runs the
+// test_* functions of every *_test.tiny file and returns 1 if any failed.
+func runTest(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	verbose := flags.Bool("v", false, "list every test as it passes")
+	pattern := flags.String("run", "", "run only the tests whose names match `regexp`")
+	junitPath := flags.String("junit", "", "write a JUnit XML report to `file`")
+	cover := flags.Bool("cover", false, "print statement and branch coverage of the test files")
+	coverProfile := flags.String("coverprofile", "", "write an LCOV coverage report to `file`; implies -cover")
+	coverHTML := flags.String("coverhtml", "", "write an annotated HTML coverage view to `file`; implies -cover")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tinylang test [-v] [-run regexp] [-junit file] [-cover] [file_test.tiny | dir ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	filter, err := regexp.Compile(*pattern)
+	if err != nil {
+		fmt.Fprintf(stderr, "test: invalid -run pattern: %v\n", err)
+		return exitUsage
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testFiles(paths)
+	if err != nil {
+		fmt.Fprintf(stderr, "test: %v\n", err)
+		return exitUsage
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(stdout, "no test files")
+		return exitOK
+	}
+
+	var coverage *Coverage
+	if *cover || *coverProfile != "" || *coverHTML != "" {
+		coverage = NewCoverage()
+	}
+
+	var results []testFileResult
+	passed, failed := 0, 0
+	status := exitOK
+	for _, path := range files {
+		result := runTestFile(path, filter, coverage, stdout)
+		results = append(results, result)
+		printTestFileResult(stdout, result, *verbose)
+		failed += result.failed()
+		passed += len(result.Tests) - result.failed()
+		if result.ParseErrors != nil {
+			failed++
+			status = exitParseError
+		}
+	}
+
+	if failed > 0 {
+		if status == exitOK {
+			status = exitRuntimeError
+		}
+		fmt.Fprintf(stdout, "\nFAIL: %d passed, %d failed\n", passed, failed)
+	} else {
+		fmt.Fprintf(stdout, "\nPASS: %d passed\n", passed)
+	}
+
+	if *junitPath != "" {
+		if err := writeFileWith(*junitPath, func(w io.Writer) error { return writeJUnit(w, results) }); err != nil {
+			fmt.Fprintf(stderr, "test: %v\n", err)
+			return exitUsage
+		}
+	}
+	if coverage != nil {
+		fmt.Fprintln(stdout, coverage.Summary())
+		if err := writeCoverage(coverage, *coverProfile, *coverHTML); err != nil {
+			fmt.Fprintf(stderr, "test: %v\n", err)
+			return exitUsage
+		}
+	}
+	return status
+}
+
+// testFiles expands directories into the *_test.tiny files they contain
+func testFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, "_test.tiny") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// runTestFile runs the matching tests of one file. Each test gets a fresh
+// environment in which the file's top-level code runs first.
+func runTestFile(path string, filter *regexp.Regexp, coverage *Coverage, stdout io.Writer) testFileResult {
+	result := testFileResult{Path: path}
+	start := time.Now()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		result.ParseErrors = []string{err.Error()}
+		return result
+	}
+	parser := NewParser(New(string(content)))
+	program := parser.ParseProgram()
+	if len(parser.Errors()) > 0 {
+		result.ParseErrors = parser.Errors()
+		return result
+	}
+	if coverage != nil {
+		coverage.AddFile(path, string(content), program)
+	}
+
+	for _, stmt := range program.Statements {
+		fn, ok := stmt.(*FunctionStatement)
+		if !ok || !strings.HasPrefix(fn.Name.Value, "test_") || !filter.MatchString(fn.Name.Value) {
+			continue
+		}
+		result.Tests = append(result.Tests, runTestFunction(program, fn.Name.Value, coverage, stdout))
+	}
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+// runTestFunction runs the program in a fresh environment and then calls
+// the named test function, as a running task of the program's run so
+// that blocking on a channel is not taken for a deadlock
+func runTestFunction(program *Program, name string, coverage *Coverage, stdout io.Writer) testResult {
+	start := time.Now()
+	env := NewEnvironment()
+	env.rt.SetOutput(stdout)
+	if coverage != nil {
+		env.AddHooks(coverage)
+	}
+
+	outcome := Eval(program, env)
+	if !isError(outcome) {
+		fn, _ := env.Get(name)
+		env.rt.enter()
+		outcome = applyFunction(fn, nil, env)
+		env.rt.exit()
+	}
+
+	result := testResult{Name: name, Elapsed: time.Since(start)}
+	if err, ok := outcome.(*Error); ok {
+		result.Failure = err
+	}
+	return result
+}
+
+// testFailureMessage describes why a test failed, with the position of
+// the failing code
+func testFailureMessage(path string, err *Error) string {
+	message := err.Message
+	if err.Kind != ASSERTION_ERROR {
+		message = err.Kind + ": " + message
+	}
+	if err.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", path, err.Line, err.Column, message)
+	}
+	return fmt.Sprintf("%s: %s", path, message)
+}
+
+// printTestFileResult reports failures, and with verbose every test, then
+// a line for the file
+func printTestFileResult(w io.Writer, result testFileResult, verbose bool) {
+	if result.ParseErrors != nil {
+		fmt.Fprintf(w, "FAIL %s: parse errors\n", result.Path)
+		for _, msg := range result.ParseErrors {
+			fmt.Fprintf(w, "    %s\n", msg)
+		}
+		return
+	}
+
+	for _, t := range result.Tests {
+		if t.Failure != nil {
+			fmt.Fprintf(w, "--- FAIL: %s\n    %s\n", t.Name, testFailureMessage(result.Path, t.Failure))
+		} else if verbose {
+			fmt.Fprintf(w, "--- PASS: %s\n", t.Name)
+		}
+	}
+
+	switch failed := result.failed(); {
+	case len(result.Tests) == 0:
+		fmt.Fprintf(w, "ok   %s (no tests)\n", result.Path)
+	case failed > 0:
+		fmt.Fprintf(w, "FAIL %s (%d passed, %d failed)\n", result.Path, len(result.Tests)-failed, failed)
+	default:
+		fmt.Fprintf(w, "ok   %s (%d passed)\n", result.Path, len(result.Tests))
+	}
+}
+
+// JUnit XML report elements
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+	Error    *junitError `xml:"error,omitempty"`
+}
+
+type junitCase struct {
+	Name      string      `xml:"name,attr"`
+	ClassName string      `xml:"classname,attr"`
+	Time      string      `xml:"time,attr"`
+	Failure   *junitError `xml:"failure,omitempty"`
+}
+
+type junitError struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitTime formats a duration in seconds
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit writes the results as a JUnit XML report, one suite per file
+func writeJUnit(w io.Writer, results []testFileResult) error {
+	var report junitSuites
+	var total time.Duration
+	for _, r := range results {
+		suite := junitSuite{Name: r.Path, Tests: len(r.Tests), Failures: r.failed(), Time: junitTime(r.Elapsed)}
+		if r.ParseErrors != nil {
+			suite.Errors = 1
+			suite.Error = &junitError{Message: "parse errors", Type: "ParseError", Text: strings.Join(r.ParseErrors, "\n")}
+		}
+		for _, t := range r.Tests {
+			c := junitCase{Name: t.Name, ClassName: r.Path, Time: junitTime(t.Elapsed)}
+			if t.Failure != nil {
+				c.Failure = &junitError{
+					Message: t.Failure.Message,
+					Type:    t.Failure.Kind,
+					Text:    testFailureMessage(r.Path, t.Failure),
+				}
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		total += r.Elapsed
+	}
+	report.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the failure message, or "" when the assertion holds
+	}{
+		{`assert_eq(1 + 1, 2);`, ""},
+		{listFunc + `assert_eq(list(1, "a", list(ok(2))), list(1, "a", list(ok(2))));`, ""},
+		{listFunc + `assert_eq(list(1, 2), list(1, 3));`, "assert_eq failed: got [1, 2], want [1, 3]"},
+		{`assert_eq(1, "1", "types differ");`, "assert_eq failed: got 1, want 1: types differ"},
+		{`assert_eq(err("x"), err("x"));`, ""},
+		{`assert_true(1 < 2);`, ""},
+		{`assert_true(false, "must hold");`, "assert_true failed: got false: must hold"},
+		{`func boom() { throw "x"; }
+		  let e = assert_error(boom);
+		  assert_eq(is_err(e), true);`, ""},
+		{`func half(n) { return n / 2; }
+		  assert_error(half, 4);`, "assert_error failed: half(4) returned 2"},
+		{`assert_error(int, "abc");`, ""},
+	}
+
+	for _, tt := range tests {
+		result := testEval(tt.input)
+		err, failed := result.(*Error)
+		switch {
+		case tt.expected == "" && failed:
+			t.Errorf("%q failed: %s", tt.input, err.Message)
+		case tt.expected != "" && !failed:
+			t.Errorf("%q: expected failure %q, got %s", tt.input, tt.expected, result.Inspect())
+		case failed && (err.Message != tt.expected || err.Kind != ASSERTION_ERROR):
+			t.Errorf("%q: wrong failure %s %q, want %q", tt.input, err.Kind, err.Message, tt.expected)
+		}
+	}
+}
+
+const testRunnerFile = `func add(a, b) {
+    return a + b;
+}
+let log = chan(2);
+
+func test_add() {
+    send(log, "add");
+    assert_eq(add(1, 2), 3);
+}
+func test_fresh_environment() {
+    send(log, "fresh");
+    assert_eq(recv(log), "fresh");
+}
+func test_fails() {
+    assert_eq(add(2, 2), 5, "sum");
+}
+func test_runtime_error() {
+    add(1, "x");
+}
+func helper() {
+    return 1;
+}
+`
+
+func TestRunTest(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"math_test.tiny":       testRunnerFile,
+		"sub/other_test.tiny":  "func test_ok() {\n    assert_true(true);\n}\n",
+		"not_a_test.tiny":      "func test_ignored() {\n    assert_true(false);\n}\n",
+		"sub/broken_test.tiny": "let = 1;\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	math := filepath.Join(dir, "math_test.tiny")
+
+	var stdout, stderr bytes.Buffer
+	junit := filepath.Join(dir, "report.xml")
+	code := runTest([]string{"-v", "-junit", junit, math, filepath.Join(dir, "sub", "other_test.tiny")}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+
+	expected := `--- PASS: test_add
+--- PASS: test_fresh_environment
+--- FAIL: test_fails
+    MATH:15:14: assert_eq failed: got 4, want 5: sum
+--- FAIL: test_runtime_error
+    MATH:2:14: TypeError: type mismatch: INTEGER + STRING
+FAIL MATH (2 passed, 2 failed)
+--- PASS: test_ok
+ok   OTHER (1 passed)
+
+FAIL: 3 passed, 2 failed
+`
+	expected = strings.NewReplacer("MATH", math, "OTHER", filepath.Join(dir, "sub", "other_test.tiny")).Replace(expected)
+	if stdout.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+
+	report, err := os.ReadFile(junit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuites tests="5" failures="2" errors="0"`,
+		`<testcase name="test_add" classname="` + math + `"`,
+		`<failure message="assert_eq failed: got 4, want 5: sum" type="AssertionError">`,
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("JUnit report is missing %s:\n%s", want, report)
+		}
+	}
+
+	// -run filters tests, and directories are searched for test files
+	stdout.Reset()
+	if code := runTest([]string{"-run", "^test_ok$|add", dir}, &stdout, &stderr); code != exitParseError {
+		t.Errorf("expected exit code %d for the unparsable file, got %d", exitParseError, code)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "ok   "+math+" (1 passed)") || !strings.Contains(out, "broken_test.tiny: parse errors") ||
+		strings.Contains(out, "test_ignored") || !strings.HasSuffix(out, "FAIL: 2 passed, 1 failed\n") {
+		t.Errorf("wrong filtered output:\n%s", out)
+	}
+
+	stdout.Reset()
+	if code := runTest([]string{"-run", "add", "-cover", math}, &stdout, &stderr); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "PASS: 1 passed\ncoverage: ") {
+		t.Errorf("expected a coverage summary:\n%s", stdout.String())
+	}
+}
+
+func TestRunTestConcurrency(t *testing.T) {
+	path := writeTinyFile(t, `func work(n) {
+    return n * 2;
+}
+func test_spawn() {
+    assert_eq(await(spawn work(21)), 42);
+}
+func test_channels() {
+    let c = chan();
+    spawn send(c, "ping");
+    assert_eq(recv(c), "ping");
+}
+func test_deadlock() {
+    recv(chan());
+}
+`)
+	var stdout, stderr bytes.Buffer
+	if code := runTest([]string{"-v", path}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "--- PASS: test_spawn\n--- PASS: test_channels\n--- FAIL: test_deadlock\n") ||
+		!strings.Contains(out, "DeadlockError: deadlock: all tasks are blocked") {
+		t.Errorf("wrong output:\n%s", out)
+	}
+}
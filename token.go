@@ -10,11 +10,13 @@ const (
 	// Special tokens
 	ILLEGAL TokenType = iota
 	EOF
+	COMMENT // only emitted by a lexer in trivia mode
 
 	// Identifiers and literals
-	IDENT  // variable names, function names
-	INT    // integers like 123
-	STRING // strings like "hello"
+	IDENT   // variable names, function names
+	INT     // integers like 123
+	STRING  // strings like "hello"
+	DECIMAL // decimals like 12.50d
 
 	// Operators
 	ASSIGN   // =
@@ -36,13 +38,21 @@ const (
 	OR  // ||
 	NOT // !
 
+	// Postfix operators
+	QUESTION // ?
+
 	// Delimiters
 	COMMA     // ,
+	DOT       // .
+	ELLIPSIS  // ...
+	COLON     // :
 	SEMICOLON // ;
 	LPAREN    // (
 	RPAREN    // )
 	LBRACE    // {
 	RBRACE    // }
+	LBRACKET  // [
+	RBRACKET  // ]
 
 	// Keywords
 	FUNCTION // func
@@ -52,6 +62,11 @@ const (
 	IF       // if
 	ELSE     // else
 	RETURN   // return
+	SPAWN    // spawn
+	TRY      // try
+	CATCH    // catch
+	FINALLY  // finally
+	THROW    // throw
 )
 
 // Token represents a single token
@@ -60,16 +75,24 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+
+	// Leading and Trailing hold the whitespace around the token when the
+	// lexer runs in trivia mode. Trailing runs up to and including the
+	// first newline after the token; everything else is Leading.
+	Leading  string
+	Trailing string
 }
 
 // tokenTypeNames maps token types to their string representations
 var tokenTypeNames = map[TokenType]string{
 	ILLEGAL: "ILLEGAL",
 	EOF:     "EOF",
+	COMMENT: "COMMENT",
 
-	IDENT:  "IDENT",
-	INT:    "INT",
-	STRING: "STRING",
+	IDENT:   "IDENT",
+	INT:     "INT",
+	STRING:  "STRING",
+	DECIMAL: "DECIMAL",
 
 	ASSIGN:   "=",
 	PLUS:     "+",
@@ -88,12 +111,19 @@ var tokenTypeNames = map[TokenType]string{
 	OR:  "||",
 	NOT: "!",
 
+	QUESTION: "?",
+
 	COMMA:     ",",
+	DOT:       ".",
+	ELLIPSIS:  "...",
+	COLON:     ":",
 	SEMICOLON: ";",
 	LPAREN:    "(",
 	RPAREN:    ")",
 	LBRACE:    "{",
 	RBRACE:    "}",
+	LBRACKET:  "[",
+	RBRACKET:  "]",
 
 	FUNCTION: "FUNCTION",
 	LET:      "LET",
@@ -102,6 +132,11 @@ var tokenTypeNames = map[TokenType]string{
 	IF:       "IF",
 	ELSE:     "ELSE",
 	RETURN:   "RETURN",
+	SPAWN:    "SPAWN",
+	TRY:      "TRY",
+	CATCH:    "CATCH",
+	FINALLY:  "FINALLY",
+	THROW:    "THROW",
 }
 
 // String returns the string representation of a token type
@@ -118,15 +153,28 @@ func (t Token) String() string {
 		t.Type, t.Literal, t.Line, t.Column)
 }
 
+// Source returns the token as it was written in the input, without trivia
+func (t Token) Source() string {
+	if t.Type == STRING {
+		return `"` + t.Literal + `"`
+	}
+	return t.Literal
+}
+
 // keywords maps string literals to their corresponding TokenType
 var keywords = map[string]TokenType{
-	"func":   FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"func":    FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"spawn":   SPAWN,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
 }
 
 // LookupIdent checks if an identifier is a keyword
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Tracer prints every call a program makes, indented by call depth, with
+// its arguments and the value it returns
+type Tracer struct {
+	BaseHooks
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewTracer creates a tracer writing to out
+func NewTracer(out io.Writer) *Tracer {
+	return &Tracer{out: out}
+}
+
+// OnCall implements EvalHooks
+func (t *Tracer) OnCall(node Node, fn Object, args []Object, pos Token, env *Environment) {
+	inspected := make([]string, len(args))
+	for i, arg := range args {
+		inspected[i] = arg.Inspect()
+	}
+	t.printf(env, "-> %s(%s) (line %d)", traceName(fn), strings.Join(inspected, ", "), pos.Line)
+}
+
+// OnReturn implements EvalHooks
+func (t *Tracer) OnReturn(node Node, fn Object, result Object, pos Token, env *Environment) {
+	value := "null"
+	if result != nil {
+		value = result.Inspect()
+	}
+	t.printf(env, "<- %s: %s", traceName(fn), value)
+}
+
+// printf writes one trace line indented to the depth of the call site
+func (t *Tracer) printf(env *Environment, format string, args ...interface{}) {
+	var prefix string
+	if f := env.frame; f != nil {
+		if f.TaskID != 0 {
+			prefix = fmt.Sprintf("[task %d] ", f.TaskID)
+		}
+		prefix += strings.Repeat("  ", f.Depth)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.out, prefix+format+"\n", args...)
+}
+
+// traceName returns the name a call is traced under
+func traceName(fn Object) string {
+	switch fn := fn.(type) {
+	case *Function:
+		return fn.Name
+	case *Builtin:
+		return fn.Name
+	}
+	return fn.Inspect()
+}